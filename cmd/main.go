@@ -1,60 +1,91 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"time"
 
 	"github.com/nsxbet/zendesk_exporter/internal/collector"
+	"github.com/nsxbet/zendesk_exporter/internal/config"
+	"github.com/nsxbet/zendesk_exporter/internal/exporter"
 
 	"github.com/alecthomas/kingpin/v2"
-	"github.com/nukosuke/go-zendesk/zendesk"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/exporter-toolkit/web"
+	webflag "github.com/prometheus/exporter-toolkit/web/kingpinflag"
 )
 
 var (
-	listenAddress = kingpin.Flag("web.listen-address", "Address to listen on for web interface and telemetry.").Default(":9101").String()
-	metricsPath   = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
+	toolkitFlags = webflag.AddFlags(kingpin.CommandLine, ":9101")
+	metricsPath  = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
+	configFile   = kingpin.Flag("config.file", "Path to the YAML configuration file. Settings in the file take precedence over flags and environment variables.").String()
+
+	ticketsSource   = kingpin.Flag("tickets.source", "API used to fetch tickets: incremental (Incremental Ticket Export, requires admin) or search (Search API, capped at 1000 results per status).").Default("incremental").Enum("incremental", "search")
+	maxRetries      = kingpin.Flag("zendesk.max-retries", "Maximum number of retries for rate limited or failed Zendesk API requests.").Default("5").Int()
+	ticketsInterval = kingpin.Flag("tickets.refresh-interval", "Interval between refreshes of the shared ticket snapshot used by the tags_tickets, custom_fields, tickets, numeric_fields and ticket_metrics collectors.").Default("5m").Duration()
+	fieldsInterval  = kingpin.Flag("tickets.fields-refresh-interval", "Interval between refreshes of the ticket field definitions used to label custom fields.").Default("1h").Duration()
 )
 
-func getEnvOrFatal(key string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		log.Fatalf("Environment variable %s must be set", key)
+// loadConfig builds the configuration from flags and environment variables
+// and applies the configuration file on top of them
+func loadConfig() (*config.Config, error) {
+	cfg := config.Default()
+	cfg.Zendesk.Subdomain = os.Getenv("ZENDESK_DOMAIN")
+	cfg.Zendesk.Email = os.Getenv("ZENDESK_EMAIL")
+	cfg.Zendesk.APIToken = os.Getenv("ZENDESK_API_TOKEN")
+	cfg.Zendesk.APITokenFile = os.Getenv("ZENDESK_API_TOKEN_FILE")
+	cfg.Zendesk.OAuthToken = os.Getenv("ZENDESK_OAUTH_TOKEN")
+	cfg.Zendesk.OAuthTokenFile = os.Getenv("ZENDESK_OAUTH_TOKEN_FILE")
+	cfg.Zendesk.MaxRetries = *maxRetries
+	cfg.Tickets.Source = *ticketsSource
+	cfg.Tickets.RefreshInterval = model.Duration(*ticketsInterval)
+	cfg.Tickets.FieldsRefreshInterval = model.Duration(*fieldsInterval)
+
+	if *configFile != "" {
+		var err error
+		cfg, err = config.Load(*configFile, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(collector.Names()); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
-	return value
+	if err := collector.ValidateFlags(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
 }
 
 func main() {
 	kingpin.Parse()
 
-	// Get Zendesk credentials from environment variables
-	zendeskDomain := getEnvOrFatal("ZENDESK_DOMAIN")
-	zendeskEmail := getEnvOrFatal("ZENDESK_EMAIL")
-	zendeskAPIToken := getEnvOrFatal("ZENDESK_API_TOKEN")
-
-	zendeskClient := newZendeskClient(
-		zendeskDomain,
-		zendeskEmail,
-		zendeskAPIToken,
-	)
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
-	// Create and register collectors
-	allTimeCollector := collector.NewAllTimeTicketsCollector(zendeskClient)
-	recentCollector := collector.NewRecentTicketsCollector(zendeskClient)
-	tagsCollector := collector.NewTagsTicketsCollector(zendeskClient)
-	customFieldsCollector := collector.NewCustomFieldsCollector(zendeskClient)
-	ticketsCollector := collector.NewTicketsCollector(zendeskClient)
-	prometheus.MustRegister(allTimeCollector)
-	prometheus.MustRegister(recentCollector)
-	prometheus.MustRegister(tagsCollector)
-	prometheus.MustRegister(customFieldsCollector)
-	prometheus.MustRegister(ticketsCollector)
+	// The default account is served on the metrics path
+	if cfg.Zendesk.Subdomain != "" {
+		e, err := exporter.New(context.Background(), cfg, cfg.Zendesk, prometheus.DefaultRegisterer)
+		if err != nil {
+			log.Fatalf("Failed to create collectors: %v", err)
+		}
+		go e.Run(context.Background())
+	}
 
 	// Setup HTTP server
-	http.Handle(*metricsPath, promhttp.Handler())
+	http.Handle(*metricsPath, promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, exporter.HandlerOpts),
+	))
+	http.Handle("/probe", exporter.NewProbeHandler(cfg))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
 			<head><title>Zendesk Exporter</title></head>
@@ -65,22 +96,9 @@ func main() {
 			</html>`))
 	})
 
-	log.Printf("Starting Zendesk exporter on %s", *listenAddress)
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
-}
-
-func newZendeskClient(domain, email, apiToken string) *zendesk.Client {
-	if domain == "" || email == "" || apiToken == "" {
-		log.Fatalf("Missing required environment variables")
-	}
-
-	client, err := zendesk.NewClient(&http.Client{Timeout: time.Second * 30})
-	if err != nil {
-		log.Fatalf("Failed to create client: %v", err)
+	// TLS and authentication from --web.config.file apply to every endpoint
+	server := &http.Server{}
+	if err := web.ListenAndServe(server, toolkitFlags, slog.Default()); err != nil {
+		log.Fatal(err)
 	}
-
-	client.SetSubdomain(domain)
-	client.SetCredential(zendesk.NewAPITokenCredential(email, apiToken))
-
-	return client
 }
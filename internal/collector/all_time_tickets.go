@@ -2,16 +2,27 @@ package collector
 
 import (
 	"context"
-	"log"
+	"fmt"
 
 	"github.com/nukosuke/go-zendesk/zendesk"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+var allTimeRefreshInterval = registerRefreshInterval("all_time_tickets", "10m")
+
+func init() {
+	registerCollector("all_time_tickets", true, func(cfg *Config) (prometheus.Collector, error) {
+		c := NewAllTimeTicketsCollector(cfg.Client)
+		cfg.Scheduler.Add("all_time_tickets", c, cfg.refreshInterval("all_time_tickets", *allTimeRefreshInterval))
+		return c, nil
+	})
+}
+
 // AllTimeTicketsCollector collects total number of tickets across all time
 type AllTimeTicketsCollector struct {
 	client *zendesk.Client
 	total  *prometheus.Desc
+	cache  metricCache
 }
 
 // NewAllTimeTicketsCollector creates a new AllTimeTicketsCollector
@@ -33,24 +44,27 @@ func (c *AllTimeTicketsCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements prometheus.Collector
 func (c *AllTimeTicketsCollector) Collect(ch chan<- prometheus.Metric) {
-	ctx := context.Background()
+	c.cache.collect(ch)
+}
 
+// Update implements Updater
+func (c *AllTimeTicketsCollector) Update(ctx context.Context) error {
 	count, err := c.client.SearchCount(ctx, &zendesk.CountOptions{
 		Query: "type:ticket",
 	})
 	if err != nil {
-		log.Printf("Error getting all-time ticket count: %v", err)
-		ch <- prometheus.MustNewConstMetric(
+		err = fmt.Errorf("error getting all-time ticket count: %w", err)
+		c.cache.setError(c, err)
+		return err
+	}
+
+	c.cache.set([]prometheus.Metric{
+		prometheus.MustNewConstMetric(
 			c.total,
 			prometheus.GaugeValue,
-			0,
-		)
-		return
-	}
+			float64(count),
+		),
+	})
 
-	ch <- prometheus.MustNewConstMetric(
-		c.total,
-		prometheus.GaugeValue,
-		float64(count),
-	)
+	return nil
 }
@@ -0,0 +1,202 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+var backlogRefreshInterval = registerRefreshInterval("backlog", "10m")
+
+// unsolvedStatuses are the statuses of the tickets in the backlog
+var unsolvedStatuses = []string{"new", "open", "pending", "hold"}
+
+func init() {
+	registerCollector("backlog", true, func(cfg *Config) (prometheus.Collector, error) {
+		c := NewBacklogCollector(cfg.Client, cfg.Groups, cfg.Backlog.AgeBuckets)
+		cfg.Scheduler.Add("backlog", c, cfg.refreshInterval("backlog", *backlogRefreshInterval))
+		return c, nil
+	})
+}
+
+// BacklogCollector collects the unsolved tickets regardless of when they
+// were created, using the search count API
+type BacklogCollector struct {
+	client     *zendesk.Client
+	groups     *GroupCache
+	ageBuckets []model.Duration
+	count      *prometheus.Desc
+	total      *prometheus.Desc
+	oldest     *prometheus.Desc
+	cache      metricCache
+}
+
+// NewBacklogCollector creates a new BacklogCollector. The age buckets are
+// the increasing upper bounds of the age ranges tickets are counted in.
+func NewBacklogCollector(client *zendesk.Client, groups *GroupCache, ageBuckets []model.Duration) *BacklogCollector {
+	return &BacklogCollector{
+		client:     client,
+		groups:     groups,
+		ageBuckets: ageBuckets,
+		count: prometheus.NewDesc(
+			"zendesk_tickets_backlog_count",
+			"Number of unsolved tickets by status and age",
+			[]string{"status", "age"}, nil,
+		),
+		total: prometheus.NewDesc(
+			"zendesk_tickets_backlog_total",
+			"Number of unsolved tickets by status",
+			[]string{"status"}, nil,
+		),
+		oldest: prometheus.NewDesc(
+			"zendesk_tickets_backlog_oldest_age_seconds",
+			"Age of the oldest unsolved ticket by status and group",
+			[]string{"status", "group"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *BacklogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.count
+	ch <- c.total
+	ch <- c.oldest
+}
+
+// Collect implements prometheus.Collector
+func (c *BacklogCollector) Collect(ch chan<- prometheus.Metric) {
+	c.cache.collect(ch)
+}
+
+// ageRange is a range of ticket ages with its label value
+type ageRange struct {
+	label    string
+	min, max time.Duration // max is 0 for the last range
+}
+
+// ageRanges returns the age ranges delimited by the buckets, such as <1d,
+// 1d-3d and >30d
+func (c *BacklogCollector) ageRanges() []ageRange {
+	ranges := make([]ageRange, 0, len(c.ageBuckets)+1)
+
+	var min model.Duration
+	for _, max := range c.ageBuckets {
+		label := "<" + max.String()
+		if min > 0 {
+			label = min.String() + "-" + max.String()
+		}
+		ranges = append(ranges, ageRange{label: label, min: time.Duration(min), max: time.Duration(max)})
+		min = max
+	}
+	return append(ranges, ageRange{label: ">" + min.String(), min: time.Duration(min)})
+}
+
+// query returns the search query filtering the tickets within the range
+func (r ageRange) query(now time.Time) string {
+	if r.max == 0 {
+		return fmt.Sprintf("created<%s", now.Add(-r.min).UTC().Format(time.RFC3339))
+	}
+	return timeRange{from: now.Add(-r.max), to: now.Add(-r.min)}.query()
+}
+
+// Update implements Updater
+func (c *BacklogCollector) Update(ctx context.Context) error {
+	now := time.Now()
+	ranges := c.ageRanges()
+
+	var out []prometheus.Metric
+
+	for _, status := range unsolvedStatuses {
+		// Count tickets in each age range
+		var total int
+		for _, r := range ranges {
+			count, err := c.client.SearchCount(ctx, &zendesk.CountOptions{
+				Query: fmt.Sprintf("%s status:%s type:ticket", r.query(now), status),
+			})
+			if err != nil {
+				err = fmt.Errorf("error counting %s tickets aged %s: %w", status, r.label, err)
+				c.cache.setError(c, err)
+				return err
+			}
+
+			out = append(out, prometheus.MustNewConstMetric(
+				c.count,
+				prometheus.GaugeValue,
+				float64(count),
+				status,
+				r.label,
+			))
+			total += count
+		}
+
+		out = append(out, prometheus.MustNewConstMetric(
+			c.total,
+			prometheus.GaugeValue,
+			float64(total),
+			status,
+		))
+
+		// Find the oldest ticket of each group, including tickets without one
+		groups := append([]int64{0}, c.groups.IDs()...)
+		for _, groupID := range groups {
+			group := "none"
+			if groupID != 0 {
+				group = strconv.FormatInt(groupID, 10)
+			}
+
+			createdAt, ok, err := c.oldestTicket(ctx, fmt.Sprintf("status:%s group:%s type:ticket", status, group))
+			if err != nil {
+				err = fmt.Errorf("error searching the oldest %s ticket of group %s: %w", status, group, err)
+				c.cache.setError(c, err)
+				return err
+			}
+			if !ok {
+				continue
+			}
+
+			out = append(out, prometheus.MustNewConstMetric(
+				c.oldest,
+				prometheus.GaugeValue,
+				now.Sub(createdAt).Seconds(),
+				status,
+				c.groups.Name(groupID),
+			))
+		}
+
+		log.Printf("Collected backlog of %s tickets: %d", status, total)
+	}
+
+	c.cache.set(out)
+
+	return nil
+}
+
+// oldestTicket returns the creation time of the oldest ticket matching a
+// query, and whether there is one
+func (c *BacklogCollector) oldestTicket(ctx context.Context, query string) (time.Time, bool, error) {
+	results, _, err := c.client.Search(ctx, &zendesk.SearchOptions{
+		PageOptions: zendesk.PageOptions{
+			Page:    1,
+			PerPage: 1,
+		},
+		Query:     query,
+		SortBy:    "created_at",
+		SortOrder: "asc",
+	})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	for _, item := range results.List() {
+		if ticket, ok := item.(zendesk.Ticket); ok && ticket.CreatedAt != nil {
+			return *ticket.CreatedAt, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
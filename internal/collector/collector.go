@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/nsxbet/zendesk_exporter/internal/config"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Config holds the dependencies and settings shared by all collectors
+type Config struct {
+	Client        *zendesk.Client
+	Tickets       *TicketStore
+	Fields        *TicketFieldCache
+	Groups        *GroupCache
+	Scheduler     *Scheduler
+	StatusTracker *StatusTracker
+	Limiter       *SeriesLimiter
+	Statuses      []string
+	Windows       []config.Window
+	Collectors    map[string]config.CollectorConfig
+	Labels        config.LabelsConfig
+	NumericFields []config.NumericFieldConfig
+	TicketMetrics config.TicketMetricsConfig
+	Backlog       config.BacklogConfig
+}
+
+// refreshInterval returns the configured refresh interval of a collector,
+// or fallback when the configuration doesn't set one
+func (c *Config) refreshInterval(name string, fallback time.Duration) time.Duration {
+	if interval := c.Collectors[name].RefreshInterval; interval > 0 {
+		return time.Duration(interval)
+	}
+	return fallback
+}
+
+// Factory creates a collector from the shared dependencies
+type Factory func(cfg *Config) (prometheus.Collector, error)
+
+var (
+	factories        = make(map[string]Factory)
+	collectorState   = make(map[string]*bool)
+	refreshIntervals = make(map[string]*time.Duration) // collectors refreshed on their own schedule
+)
+
+// registerCollector makes a collector available under a name and adds the
+// --collector.<name> flag to enable or disable it
+func registerCollector(name string, isDefaultEnabled bool, factory Factory) {
+	helpDefaultState := "disabled"
+	if isDefaultEnabled {
+		helpDefaultState = "enabled"
+	}
+
+	flagName := fmt.Sprintf("collector.%s", name)
+	flagHelp := fmt.Sprintf("Enable the %s collector (default: %s).", name, helpDefaultState)
+	defaultValue := fmt.Sprintf("%v", isDefaultEnabled)
+
+	collectorState[name] = kingpin.Flag(flagName, flagHelp).Default(defaultValue).Bool()
+	factories[name] = factory
+}
+
+// registerRefreshInterval adds the --collector.<name>.refresh-interval flag
+// of a collector that is refreshed on its own schedule rather than with the
+// shared ticket snapshot
+func registerRefreshInterval(name, defaultValue string) *time.Duration {
+	flagName := fmt.Sprintf("collector.%s.refresh-interval", name)
+	flagHelp := fmt.Sprintf("Interval between refreshes of the %s collector.", name)
+
+	refreshIntervals[name] = kingpin.Flag(flagName, flagHelp).Default(defaultValue).Duration()
+	return refreshIntervals[name]
+}
+
+// Names returns the name of every collector, mapped to whether it is
+// refreshed on its own schedule and accepts a refresh interval
+func Names() map[string]bool {
+	names := make(map[string]bool, len(factories))
+	for name := range factories {
+		_, scheduled := refreshIntervals[name]
+		names[name] = scheduled
+	}
+	return names
+}
+
+// ValidateFlags checks that the refresh interval flags are positive
+func ValidateFlags() error {
+	for name, interval := range refreshIntervals {
+		if *interval <= 0 {
+			return fmt.Errorf("--collector.%s.refresh-interval must be positive, got %s", name, *interval)
+		}
+	}
+	return nil
+}
+
+// NewCollectors creates every enabled collector, keyed by name. The
+// configuration takes precedence over the --collector.<name> flags.
+func NewCollectors(cfg *Config) (map[string]prometheus.Collector, error) {
+	for name := range cfg.Collectors {
+		if _, ok := factories[name]; !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+	}
+
+	collectors := make(map[string]prometheus.Collector)
+	for name, enabled := range collectorState {
+		if cfg.Collectors[name].Enabled != nil {
+			enabled = cfg.Collectors[name].Enabled
+		}
+		if !*enabled {
+			continue
+		}
+
+		c, err := factories[name](cfg)
+		if err != nil {
+			return nil, fmt.Errorf("error creating %s collector: %w", name, err)
+		}
+		collectors[name] = c
+	}
+
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	log.Printf("Enabled collectors: %v", names)
+
+	return collectors, nil
+}
@@ -1,75 +1,137 @@
 package collector
 
 import (
-	"context"
-	"fmt"
 	"log"
-	"sync"
+	"strconv"
 	"time"
 
-	"github.com/nukosuke/go-zendesk/zendesk"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-// CustomFieldsCollector collects ticket custom field metrics for the last 30 days
+func init() {
+	registerCollector("custom_fields", true, func(cfg *Config) (prometheus.Collector, error) {
+		c := NewCustomFieldsCollector(cfg.Fields, NewValueFilter(cfg.Labels.CustomField), cfg.Limiter)
+		cfg.Tickets.Subscribe(c)
+		return c, nil
+	})
+}
+
+// CustomFieldsCollector collects ticket custom field metrics for each window
 type CustomFieldsCollector struct {
-	client *zendesk.Client
-	fields *prometheus.Desc
-	total  *prometheus.Desc
+	fields  *TicketFieldCache
+	filter  *ValueFilter
+	limiter *SeriesLimiter
+	values  *prometheus.Desc
+	total   *prometheus.Desc
+	checked *prometheus.Desc
+	dateAge *prometheus.Desc
+	cache   metricCache
+}
+
+// dateAgeBuckets are the upper bounds of the date field age histogram.
+// Negative ages are dates in the future, such as due dates.
+var dateAgeBuckets = []float64{
+	(-7 * 24 * time.Hour).Seconds(),
+	(-24 * time.Hour).Seconds(),
+	0,
+	(24 * time.Hour).Seconds(),
+	(7 * 24 * time.Hour).Seconds(),
+	(30 * 24 * time.Hour).Seconds(),
+	(90 * 24 * time.Hour).Seconds(),
+	(365 * 24 * time.Hour).Seconds(),
 }
 
 // NewCustomFieldsCollector creates a new CustomFieldsCollector
-func NewCustomFieldsCollector(client *zendesk.Client) *CustomFieldsCollector {
+func NewCustomFieldsCollector(fields *TicketFieldCache, filter *ValueFilter, limiter *SeriesLimiter) *CustomFieldsCollector {
 	return &CustomFieldsCollector{
-		client: client,
-		fields: prometheus.NewDesc(
+		fields:  fields,
+		filter:  filter,
+		limiter: limiter,
+		values: prometheus.NewDesc(
 			"zendesk_tickets_custom_fields_count",
-			"Number of tickets by custom field value (excluding numeric values) and status created in the last 30 days",
-			[]string{"field_value", "status"}, nil,
+			"Number of tickets by custom field value and status created within the window",
+			[]string{"field_id", "field_title", "field_value", "field_value_name", "status", "window"}, nil,
 		),
 		total: prometheus.NewDesc(
 			"zendesk_tickets_custom_fields_total",
-			"Total number of tickets with non-numeric custom fields in the last 30 days",
-			[]string{"status"}, nil,
+			"Total number of tickets with exported custom fields created within the window",
+			[]string{"status", "window"}, nil,
+		),
+		checked: prometheus.NewDesc(
+			"zendesk_tickets_custom_fields_checkbox_count",
+			"Number of tickets by checkbox field state and status created within the window",
+			[]string{"field_id", "field_title", "checked", "status", "window"}, nil,
+		),
+		dateAge: prometheus.NewDesc(
+			"zendesk_tickets_custom_fields_date_age_seconds",
+			"Age of the date field values of tickets by status created within the window, negative for future dates",
+			[]string{"field_id", "field_title", "status", "window"}, nil,
 		),
 	}
 }
 
 // Describe implements prometheus.Collector
 func (c *CustomFieldsCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- c.fields
+	ch <- c.values
 	ch <- c.total
+	ch <- c.checked
+	ch <- c.dateAge
 }
 
 // Collect implements prometheus.Collector
 func (c *CustomFieldsCollector) Collect(ch chan<- prometheus.Metric) {
-	ctx := context.Background()
+	c.cache.collect(ch)
+}
 
-	now := time.Now()
-	thirtyDaysAgo := now.AddDate(0, 0, -30)
-	timeRange := fmt.Sprintf("created>%s", thirtyDaysAgo.Format("2006-01-02"))
+// TicketsFailed implements TicketConsumer
+func (c *CustomFieldsCollector) TicketsFailed(err error) {
+	c.cache.setError(c, err)
+}
 
+// UpdateTickets implements TicketConsumer
+func (c *CustomFieldsCollector) UpdateTickets(snapshot *TicketSnapshot) {
 	type statusMetrics struct {
-		fieldValues map[string]float64 // field_value -> count
+		fieldValues map[int64]map[string]float64 // field ID -> field_value -> count
+		checkboxes  map[int64]map[bool]float64   // field ID -> checked -> count
+		dateAges    map[int64][]float64          // field ID -> ages in seconds
 		total       int64
 	}
-	metrics := make(map[string]*statusMetrics)
-	var mu sync.Mutex
 
-	// Process tickets for each status
-	err := SearchByStatus(ctx, c.client, timeRange, func(status string, tickets []zendesk.Ticket) error {
-		fieldValues := make(map[string]float64)
-		var statusTotal int64
+	var out []prometheus.Metric
+	var samples []sample
 
-		for _, ticket := range tickets {
-			if len(ticket.CustomFields) > 0 {
+	for _, window := range snapshot.Windows {
+		metrics := make(map[string]*statusMetrics)
+
+		// Process tickets for each status
+		for status, tickets := range snapshot.InWindow(window) {
+			fieldValues := make(map[int64]map[string]float64)
+			checkboxes := make(map[int64]map[bool]float64)
+			dateAges := make(map[int64][]float64)
+			var statusTotal int64
+
+			for _, ticket := range tickets {
 				hasCustomField := false
 				for _, field := range ticket.CustomFields {
-					if field.Value != nil && field.Value != "" {
-						fieldValue := fmt.Sprintf("%v", field.Value)
-						// Skip if the value is numeric
-						if !isNumeric(fieldValue) {
-							fieldValues[fieldValue]++
+					if checked, ok := checkboxValue(field); ok {
+						if checkboxes[field.ID] == nil {
+							checkboxes[field.ID] = make(map[bool]float64)
+						}
+						checkboxes[field.ID][checked]++
+						continue
+					}
+					if date, ok := c.fields.Date(field); ok {
+						dateAges[field.ID] = append(dateAges[field.ID], snapshot.FetchedAt.Sub(date).Seconds())
+						continue
+					}
+
+					for _, fieldValue := range c.fields.Values(field) {
+						// Skip values dropped by the filter
+						if c.filter.Keep(fieldValue) {
+							if fieldValues[field.ID] == nil {
+								fieldValues[field.ID] = make(map[string]float64)
+							}
+							fieldValues[field.ID][fieldValue]++
 							hasCustomField = true
 						}
 					}
@@ -78,53 +140,88 @@ func (c *CustomFieldsCollector) Collect(ch chan<- prometheus.Metric) {
 					statusTotal++
 				}
 			}
-		}
 
-		mu.Lock()
-		metrics[status] = &statusMetrics{
-			fieldValues: fieldValues,
-			total:       statusTotal,
+			for id, values := range fieldValues {
+				fieldValues[id] = c.filter.group(values)
+			}
+			metrics[status] = &statusMetrics{
+				fieldValues: fieldValues,
+				checkboxes:  checkboxes,
+				dateAges:    dateAges,
+				total:       statusTotal,
+			}
 		}
-		mu.Unlock()
-
-		return nil
-	})
-
-	if err != nil {
-		log.Printf("Error collecting metrics: %v", err)
-		return
-	}
 
-	// Send all metrics at once
-	for status, statusMetric := range metrics {
-		// Send total tickets with custom fields for this status
-		ch <- prometheus.MustNewConstMetric(
-			c.total,
-			prometheus.GaugeValue,
-			float64(statusMetric.total),
-			status,
-		)
-
-		// Send metrics for each field value in this status
-		for fieldValue, count := range statusMetric.fieldValues {
-			ch <- prometheus.MustNewConstMetric(
-				c.fields,
+		// Build all metrics at once
+		for status, statusMetric := range metrics {
+			// Add total tickets with custom fields for this status
+			out = append(out, prometheus.MustNewConstMetric(
+				c.total,
 				prometheus.GaugeValue,
-				count,
-				fieldValue,
+				float64(statusMetric.total),
 				status,
-			)
+				window.Name,
+			))
+
+			// Add metrics for each field value in this status
+			for id, values := range statusMetric.fieldValues {
+				fieldID := strconv.FormatInt(id, 10)
+				title := c.fields.Title(id)
+				for fieldValue, count := range values {
+					// Dropdown options are exported with their display name too
+					name := c.fields.OptionName(id, fieldValue)
+					samples = append(samples, sample{
+						labels: []string{fieldID, title, fieldValue, name, status, window.Name},
+						value:  count,
+					})
+				}
+			}
 		}
-	}
 
-	// Log summary
-	var totalWithFields int64
-	uniqueValues := make(map[string]bool)
-	for _, sm := range metrics {
-		totalWithFields += sm.total
-		for value := range sm.fieldValues {
-			uniqueValues[value] = true
+		// Add checkbox and date fields, which have a bounded number of series
+		for status, statusMetric := range metrics {
+			for id, states := range statusMetric.checkboxes {
+				for checked, count := range states {
+					out = append(out, prometheus.MustNewConstMetric(
+						c.checked,
+						prometheus.GaugeValue,
+						count,
+						strconv.FormatInt(id, 10),
+						c.fields.Title(id),
+						strconv.FormatBool(checked),
+						status,
+						window.Name,
+					))
+				}
+			}
+
+			for id, ages := range statusMetric.dateAges {
+				count, sum, buckets := histogram(ages, dateAgeBuckets)
+				out = append(out, prometheus.MustNewConstHistogram(
+					c.dateAge,
+					count,
+					sum,
+					buckets,
+					strconv.FormatInt(id, 10),
+					c.fields.Title(id),
+					status,
+					window.Name,
+				))
+			}
 		}
+
+		// Log summary
+		var totalWithFields int64
+		uniqueFields := make(map[int64]bool)
+		for _, sm := range metrics {
+			totalWithFields += sm.total
+			for id := range sm.fieldValues {
+				uniqueFields[id] = true
+			}
+		}
+		log.Printf("Collected tickets with exported custom fields in window %s: %d, fields: %d", window.Name, totalWithFields, len(uniqueFields))
 	}
-	log.Printf("Collected tickets with non-numeric custom fields: %d, unique field values: %d", totalWithFields, len(uniqueValues))
+
+	out = append(out, c.limiter.metrics("zendesk_tickets_custom_fields_count", c.values, samples)...)
+	c.cache.set(out)
 }
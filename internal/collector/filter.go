@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/nsxbet/zendesk_exporter/internal/config"
+)
+
+// OtherValue is the label value grouping the values beyond the top N
+const OtherValue = "other"
+
+// ValueFilter decides which values of a label are exported
+type ValueFilter struct {
+	dropNumeric bool
+	allow       *regexp.Regexp
+	deny        *regexp.Regexp
+	topN        int
+}
+
+// NewValueFilter creates a ValueFilter from its configuration
+func NewValueFilter(cfg config.FilterConfig) *ValueFilter {
+	f := &ValueFilter{
+		dropNumeric: cfg.DropNumeric,
+		topN:        cfg.TopN,
+	}
+	if cfg.Allow != nil {
+		f.allow = cfg.Allow.Regexp
+	}
+	if cfg.Deny != nil {
+		f.deny = cfg.Deny.Regexp
+	}
+	return f
+}
+
+// Keep reports whether a label value should be exported
+func (f *ValueFilter) Keep(value string) bool {
+	if f.dropNumeric && isNumeric(value) {
+		return false
+	}
+	if f.allow != nil && !f.allow.MatchString(value) {
+		return false
+	}
+	if f.deny != nil && f.deny.MatchString(value) {
+		return false
+	}
+	return true
+}
+
+// top returns the values with the N highest counts, or nil when every value
+// is kept. Ties are broken by value so the selection is stable.
+func (f *ValueFilter) top(counts map[string]float64) map[string]bool {
+	if f.topN == 0 || len(counts) <= f.topN {
+		return nil
+	}
+
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+
+	kept := make(map[string]bool, f.topN)
+	for _, value := range values[:f.topN] {
+		kept[value] = true
+	}
+	return kept
+}
+
+// group sums the counts of the values beyond the top N under OtherValue
+func (f *ValueFilter) group(counts map[string]float64) map[string]float64 {
+	kept := f.top(counts)
+	if kept == nil {
+		return counts
+	}
+
+	grouped := make(map[string]float64, len(kept)+1)
+	for value, count := range counts {
+		grouped[label(value, kept)] += count
+	}
+	return grouped
+}
+
+// label returns the label value of a value given the values kept by top
+func label(value string, kept map[string]bool) string {
+	if kept != nil && !kept[value] {
+		return OtherValue
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// GroupCache holds the names of the agent groups, refreshed periodically so
+// renamed groups are picked up
+type GroupCache struct {
+	client *zendesk.Client
+
+	mu    sync.RWMutex
+	names map[int64]string // group ID -> name
+}
+
+// NewGroupCache creates a new GroupCache
+func NewGroupCache(client *zendesk.Client) *GroupCache {
+	return &GroupCache{
+		client: client,
+		names:  make(map[int64]string),
+	}
+}
+
+// Update implements Updater
+func (c *GroupCache) Update(ctx context.Context) error {
+	names := make(map[int64]string)
+
+	opts := &zendesk.GroupListOptions{
+		PageOptions: zendesk.PageOptions{
+			Page:    1,
+			PerPage: 100,
+		},
+	}
+	for {
+		groups, page, err := c.client.GetGroups(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("error fetching groups: %w", err)
+		}
+		for _, group := range groups {
+			names[group.ID] = group.Name
+		}
+
+		if !page.HasNext() {
+			break
+		}
+		opts.Page++
+	}
+
+	c.mu.Lock()
+	c.names = names
+	c.mu.Unlock()
+
+	log.Printf("Fetched %d groups", len(names))
+	return nil
+}
+
+// Name returns the label value of a group: its name, "none" for tickets
+// without a group, or the ID of a group that isn't known yet
+func (c *GroupCache) Name(id int64) string {
+	if id == 0 {
+		return "none"
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if name, ok := c.names[id]; ok {
+		return name
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// IDs returns the IDs of the known groups
+func (c *GroupCache) IDs() []int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ids := make([]int64, 0, len(c.names))
+	for id := range c.names {
+		ids = append(ids, id)
+	}
+	return ids
+}
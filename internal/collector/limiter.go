@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"log"
+	"slices"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sample is a series of a metric family before the series limit is applied
+type sample struct {
+	labels []string
+	value  float64
+}
+
+// SeriesLimiter caps the number of series of each metric family and counts
+// the series it drops
+type SeriesLimiter struct {
+	maxSeries int
+	dropped   *prometheus.CounterVec
+}
+
+// NewSeriesLimiter creates a new SeriesLimiter keeping at most maxSeries
+// series per metric family, or every series when maxSeries is 0
+func NewSeriesLimiter(maxSeries int) *SeriesLimiter {
+	return &SeriesLimiter{
+		maxSeries: maxSeries,
+		dropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "zendesk_exporter_dropped_series_total",
+				Help: "Number of series dropped because their metric family exceeded the series limit",
+			},
+			[]string{"metric"},
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (l *SeriesLimiter) Describe(ch chan<- *prometheus.Desc) {
+	l.dropped.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (l *SeriesLimiter) Collect(ch chan<- prometheus.Metric) {
+	l.dropped.Collect(ch)
+}
+
+// metrics builds the gauges of a metric family. Above the limit, the series
+// with the highest values are kept.
+func (l *SeriesLimiter) metrics(name string, desc *prometheus.Desc, samples []sample) []prometheus.Metric {
+	samples = l.keep(name, samples)
+
+	metrics := make([]prometheus.Metric, 0, len(samples))
+	for _, s := range samples {
+		metrics = append(metrics, prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, s.value, s.labels...))
+	}
+	return metrics
+}
+
+// keep returns the samples of a metric family within the limit, keeping the
+// ones with the highest values. Ties are broken by their label values so the
+// same series are kept on every refresh.
+func (l *SeriesLimiter) keep(name string, samples []sample) []sample {
+	if l.maxSeries == 0 || len(samples) <= l.maxSeries {
+		return samples
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].value != samples[j].value {
+			return samples[i].value > samples[j].value
+		}
+		return slices.Compare(samples[i].labels, samples[j].labels) < 0
+	})
+	dropped := len(samples) - l.maxSeries
+	log.Printf("Dropping %d series of %s above the limit of %d", dropped, name, l.maxSeries)
+	l.dropped.WithLabelValues(name).Add(float64(dropped))
+	return samples[:l.maxSeries]
+}
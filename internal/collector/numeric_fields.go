@@ -0,0 +1,243 @@
+package collector
+
+import (
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nsxbet/zendesk_exporter/internal/config"
+
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("numeric_fields", true, func(cfg *Config) (prometheus.Collector, error) {
+		c := NewNumericFieldsCollector(cfg.NumericFields, cfg.Fields, NewValueFilter(cfg.Labels.Tag), NewValueFilter(cfg.Labels.CustomField), cfg.Limiter)
+		// Only fetch tickets when there is something to aggregate
+		if len(cfg.NumericFields) > 0 {
+			cfg.Tickets.Subscribe(c)
+		}
+		return c, nil
+	})
+}
+
+// NumericFieldsCollector aggregates the values of numeric custom fields for
+// each window, optionally grouped by tag or by the value of another field
+type NumericFieldsCollector struct {
+	numericFields     []config.NumericFieldConfig
+	fields            *TicketFieldCache
+	tagFilter         *ValueFilter
+	customFieldFilter *ValueFilter
+	limiter           *SeriesLimiter
+	values            *prometheus.Desc
+	average           *prometheus.Desc
+	cache             metricCache
+}
+
+// NewNumericFieldsCollector creates a new NumericFieldsCollector
+func NewNumericFieldsCollector(numericFields []config.NumericFieldConfig, fields *TicketFieldCache, tagFilter, customFieldFilter *ValueFilter, limiter *SeriesLimiter) *NumericFieldsCollector {
+	return &NumericFieldsCollector{
+		numericFields:     numericFields,
+		fields:            fields,
+		tagFilter:         tagFilter,
+		customFieldFilter: customFieldFilter,
+		limiter:           limiter,
+		values: prometheus.NewDesc(
+			"zendesk_tickets_numeric_field_value",
+			"Histogram of the values of a numeric custom field by status and group for tickets created within the window",
+			[]string{"field_id", "field_title", "group", "status", "window"}, nil,
+		),
+		average: prometheus.NewDesc(
+			"zendesk_tickets_numeric_field_average",
+			"Average value of a numeric custom field by status and group for tickets created within the window",
+			[]string{"field_id", "field_title", "group", "status", "window"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *NumericFieldsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.values
+	ch <- c.average
+}
+
+// Collect implements prometheus.Collector
+func (c *NumericFieldsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.cache.collect(ch)
+}
+
+// TicketsFailed implements TicketConsumer
+func (c *NumericFieldsCollector) TicketsFailed(err error) {
+	c.cache.setError(c, err)
+}
+
+// numericGroup identifies the observations of a numeric field
+type numericGroup struct {
+	status string
+	group  string
+}
+
+// numericSeries holds the observations of a numeric field behind a histogram
+// and an average series
+type numericSeries struct {
+	labels       []string
+	observations []float64
+	buckets      []float64
+}
+
+// UpdateTickets implements TicketConsumer
+func (c *NumericFieldsCollector) UpdateTickets(snapshot *TicketSnapshot) {
+	var series []numericSeries
+
+	for _, window := range snapshot.Windows {
+		tickets := snapshot.InWindow(window)
+
+		for _, numericField := range c.numericFields {
+			observations := make(map[numericGroup][]float64)
+			for status, statusTickets := range tickets {
+				// Collect the groups of each ticket first, so the groups beyond
+				// the top N of the status are known
+				var values []float64
+				var ticketGroups [][]string
+				groupCounts := make(map[string]float64)
+				for _, ticket := range statusTickets {
+					value, ok := numericValue(ticket, numericField.FieldID)
+					if !ok {
+						continue
+					}
+					groups := c.groups(ticket, numericField)
+					for _, group := range groups {
+						groupCounts[group]++
+					}
+					values = append(values, value)
+					ticketGroups = append(ticketGroups, groups)
+				}
+				filter := c.groupFilter(numericField)
+				var topGroups map[string]bool
+				if filter != nil {
+					topGroups = filter.top(groupCounts)
+				}
+
+				for i, value := range values {
+					// Ungrouped fields have a single empty group
+					groups := map[string]bool{"": true}
+					if numericField.GroupBy != "" {
+						groups = map[string]bool{"none": true}
+					}
+					for _, group := range ticketGroups[i] {
+						groups[label(group, topGroups)] = true
+						delete(groups, "none")
+					}
+
+					for group := range groups {
+						key := numericGroup{status: status, group: group}
+						observations[key] = append(observations[key], value)
+					}
+				}
+			}
+
+			buckets := numericField.Buckets
+			if len(buckets) == 0 {
+				buckets = config.DefaultNumericBuckets
+			}
+			fieldID := strconv.FormatInt(numericField.FieldID, 10)
+			title := c.fields.Title(numericField.FieldID)
+
+			for key, values := range observations {
+				series = append(series, numericSeries{
+					labels:       []string{fieldID, title, key.group, key.status, window.Name},
+					observations: values,
+					buckets:      buckets,
+				})
+			}
+
+			log.Printf("Collected numeric field %d in window %s across %d groups", numericField.FieldID, window.Name, len(observations))
+		}
+	}
+
+	c.cache.set(c.metrics(series))
+}
+
+// metrics builds the histogram and average of every series within the
+// series limit, keeping the series with the most observations
+func (c *NumericFieldsCollector) metrics(series []numericSeries) []prometheus.Metric {
+	samples := make([]sample, 0, len(series))
+	byLabels := make(map[string]numericSeries, len(series))
+	for _, s := range series {
+		samples = append(samples, sample{labels: s.labels, value: float64(len(s.observations))})
+		byLabels[strings.Join(s.labels, "\xff")] = s
+	}
+
+	// Both families have the same series, so the same ones are dropped
+	c.limiter.keep("zendesk_tickets_numeric_field_average", slices.Clone(samples))
+	samples = c.limiter.keep("zendesk_tickets_numeric_field_value", samples)
+
+	var out []prometheus.Metric
+	for _, kept := range samples {
+		s := byLabels[strings.Join(kept.labels, "\xff")]
+		count, sum, counts := histogram(s.observations, s.buckets)
+		out = append(out, prometheus.MustNewConstHistogram(c.values, count, sum, counts, s.labels...))
+		out = append(out, prometheus.MustNewConstMetric(c.average, prometheus.GaugeValue, sum/float64(count), s.labels...))
+	}
+	return out
+}
+
+// groupFilter returns the filter of the group label values of a numeric
+// field, or nil when it isn't grouped
+func (c *NumericFieldsCollector) groupFilter(numericField config.NumericFieldConfig) *ValueFilter {
+	switch numericField.GroupBy {
+	case config.GroupByTag:
+		return c.tagFilter
+	case config.GroupByCustomField:
+		return c.customFieldFilter
+	}
+	return nil
+}
+
+// groups returns the filtered groups of a ticket for a numeric field. A
+// ticket with several tags or values is counted in each of them.
+func (c *NumericFieldsCollector) groups(ticket zendesk.Ticket, numericField config.NumericFieldConfig) []string {
+	var groups []string
+
+	switch numericField.GroupBy {
+	case config.GroupByTag:
+		for _, tag := range ticket.Tags {
+			if tag != "" && c.tagFilter.Keep(tag) {
+				groups = append(groups, tag)
+			}
+		}
+	case config.GroupByCustomField:
+		for _, field := range ticket.CustomFields {
+			if field.ID != numericField.GroupByFieldID {
+				continue
+			}
+			for _, value := range c.fields.Values(field) {
+				if c.customFieldFilter.Keep(value) {
+					groups = append(groups, value)
+				}
+			}
+		}
+	}
+	return groups
+}
+
+// numericValue returns the value of a numeric custom field of a ticket
+func numericValue(ticket zendesk.Ticket, id int64) (float64, bool) {
+	for _, field := range ticket.CustomFields {
+		if field.ID != id {
+			continue
+		}
+
+		switch value := field.Value.(type) {
+		case float64:
+			return value, true
+		case string:
+			f, err := strconv.ParseFloat(value, 64)
+			return f, err == nil
+		}
+		return 0, false
+	}
+	return 0, false
+}
@@ -4,33 +4,59 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"sync"
 	"time"
 
+	"github.com/nsxbet/zendesk_exporter/internal/config"
+
 	"github.com/nukosuke/go-zendesk/zendesk"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-// RecentTicketsCollector collects ticket metrics for the last 30 days
+var recentRefreshInterval = registerRefreshInterval("recent_tickets", "5m")
+
+func init() {
+	registerCollector("recent_tickets", true, func(cfg *Config) (prometheus.Collector, error) {
+		c := NewRecentTicketsCollector(cfg.Client, cfg.Statuses, cfg.Windows, cfg.StatusTracker)
+		cfg.Scheduler.Add("recent_tickets", c, cfg.refreshInterval("recent_tickets", *recentRefreshInterval))
+		return c, nil
+	})
+}
+
+// RecentTicketsCollector collects ticket counts for each window using the
+// search count API, without downloading any ticket
 type RecentTicketsCollector struct {
-	client *zendesk.Client
-	status *prometheus.Desc
-	total  *prometheus.Desc
+	client   *zendesk.Client
+	statuses []string
+	windows  []config.Window
+	tracker  *StatusTracker
+	last     map[recentKey]float64 // last successful count of each status and window
+	status   *prometheus.Desc
+	total    *prometheus.Desc
+	cache    metricCache
+}
+
+// recentKey identifies the count of a status within a window
+type recentKey struct {
+	status, window string
 }
 
 // NewRecentTicketsCollector creates a new RecentTicketsCollector
-func NewRecentTicketsCollector(client *zendesk.Client) *RecentTicketsCollector {
+func NewRecentTicketsCollector(client *zendesk.Client, statuses []string, windows []config.Window, tracker *StatusTracker) *RecentTicketsCollector {
 	return &RecentTicketsCollector{
-		client: client,
+		client:   client,
+		statuses: statuses,
+		windows:  windows,
+		tracker:  tracker,
+		last:     make(map[recentKey]float64),
 		status: prometheus.NewDesc(
 			"zendesk_tickets_recent_status_count",
-			"Number of tickets by status created in the last 30 days",
-			[]string{"status"}, nil,
+			"Number of tickets by status created within the window",
+			[]string{"status", "window"}, nil,
 		),
 		total: prometheus.NewDesc(
 			"zendesk_tickets_recent_status_total",
-			"Total number of tickets in the last 30 days",
-			nil, nil,
+			"Total number of tickets created within the window",
+			[]string{"window"}, nil,
 		),
 	}
 }
@@ -43,48 +69,77 @@ func (c *RecentTicketsCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements prometheus.Collector
 func (c *RecentTicketsCollector) Collect(ch chan<- prometheus.Metric) {
-	ctx := context.Background()
+	c.cache.collect(ch)
+}
 
+// Update implements Updater
+func (c *RecentTicketsCollector) Update(ctx context.Context) error {
 	now := time.Now()
-	thirtyDaysAgo := now.AddDate(0, 0, -30)
-	timeRange := fmt.Sprintf("created>%s", thirtyDaysAgo.Format("2006-01-02"))
 
-	metrics := make(map[string]float64)
-	var totalTickets int64
-	var mu sync.Mutex
+	var out []prometheus.Metric
+	failed := make(map[string]error)
 
-	// Process tickets for each status
-	err := SearchByStatus(ctx, c.client, timeRange, func(status string, tickets []zendesk.Ticket) error {
-		count := float64(len(tickets))
+	for _, window := range c.windows {
+		r := timeRange{from: window.Start(now), to: now}
 
-		mu.Lock()
-		metrics[status] = count
-		totalTickets += int64(count)
-		mu.Unlock()
+		metrics := make(map[string]float64)
+		var totalTickets int64
 
-		return nil
-	})
+		// Count tickets for each status
+		for _, status := range c.statuses {
+			key := recentKey{status: status, window: window.Name}
+			count, err := c.client.SearchCount(ctx, &zendesk.CountOptions{
+				Query: fmt.Sprintf("%s status:%s type:ticket", r.query(), status),
+			})
+			if err != nil {
+				log.Printf("Error counting %s tickets in window %s: %v", status, window.Name, err)
+				failed[status] = err
+				if last, ok := c.last[key]; ok && c.tracker.serveStale() {
+					metrics[status] = last
+					totalTickets += int64(last)
+				}
+				continue
+			}
+
+			metrics[status] = float64(count)
+			totalTickets += int64(count)
+			c.last[key] = float64(count)
+		}
 
-	if err != nil {
-		log.Printf("Error collecting metrics: %v", err)
-		return
+		// Build all metrics at once
+		for status, count := range metrics {
+			out = append(out, prometheus.MustNewConstMetric(
+				c.status,
+				prometheus.GaugeValue,
+				count,
+				status,
+				window.Name,
+			))
+		}
+
+		// A total missing some statuses would look like a drop in tickets
+		if len(metrics) == len(c.statuses) {
+			out = append(out, prometheus.MustNewConstMetric(
+				c.total,
+				prometheus.GaugeValue,
+				float64(totalTickets),
+				window.Name,
+			))
+		}
+
+		log.Printf("Collected total tickets in window %s: %d, counts by status: %v", window.Name, totalTickets, metrics)
 	}
 
-	// Send all metrics at once
-	for status, count := range metrics {
-		ch <- prometheus.MustNewConstMetric(
-			c.status,
-			prometheus.GaugeValue,
-			count,
-			status,
-		)
+	for _, status := range c.statuses {
+		c.tracker.report("recent_tickets", status, failed[status])
+	}
+	if err := c.tracker.check(failed, len(c.statuses)); err != nil {
+		err = fmt.Errorf("error counting recent tickets: %w", err)
+		c.cache.setError(c, err)
+		return err
 	}
 
-	ch <- prometheus.MustNewConstMetric(
-		c.total,
-		prometheus.GaugeValue,
-		float64(totalTickets),
-	)
+	c.cache.set(out)
 
-	log.Printf("Collected total tickets: %d, counts by status: %v", totalTickets, metrics)
+	return nil
 }
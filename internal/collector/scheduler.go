@@ -0,0 +1,184 @@
+package collector
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Updater is implemented by collectors whose metrics are refreshed in the
+// background instead of on every scrape
+type Updater interface {
+	Update(ctx context.Context) error
+}
+
+// Scheduler periodically refreshes a set of updaters, each on its own
+// interval, and reports the outcome of every refresh
+type Scheduler struct {
+	jobs []job
+
+	up          *prometheus.GaugeVec
+	duration    *prometheus.GaugeVec
+	lastSuccess *prometheus.GaugeVec
+}
+
+type job struct {
+	name      string
+	updater   Updater
+	interval  time.Duration
+	refreshed bool // already refreshed before Run, so the first refresh waits an interval
+}
+
+// NewScheduler creates a new Scheduler
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		up: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "zendesk_exporter_collector_up",
+				Help: "Whether the last refresh of the collector succeeded",
+			},
+			[]string{"collector"},
+		),
+		duration: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "zendesk_exporter_collector_duration_seconds",
+				Help: "Duration of the last refresh of the collector",
+			},
+			[]string{"collector"},
+		),
+		lastSuccess: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "zendesk_exporter_last_success_timestamp_seconds",
+				Help: "Unix timestamp of the last successful refresh of the collector",
+			},
+			[]string{"collector"},
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (s *Scheduler) Describe(ch chan<- *prometheus.Desc) {
+	s.up.Describe(ch)
+	s.duration.Describe(ch)
+	s.lastSuccess.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (s *Scheduler) Collect(ch chan<- prometheus.Metric) {
+	s.up.Collect(ch)
+	s.duration.Collect(ch)
+	s.lastSuccess.Collect(ch)
+}
+
+// Add registers an updater to be refreshed every interval
+func (s *Scheduler) Add(name string, updater Updater, interval time.Duration) {
+	s.jobs = append(s.jobs, job{
+		name:     name,
+		updater:  updater,
+		interval: interval,
+	})
+
+	// Report the collector as down until its first refresh succeeds
+	s.up.WithLabelValues(name).Set(0)
+}
+
+// AddRefreshed refreshes an updater right away and registers it to be
+// refreshed every interval. Run doesn't refresh it again on start.
+func (s *Scheduler) AddRefreshed(ctx context.Context, name string, updater Updater, interval time.Duration) {
+	s.Add(name, updater, interval)
+	j := &s.jobs[len(s.jobs)-1]
+	j.refreshed = true
+	s.update(ctx, *j)
+}
+
+// Run refreshes every registered updater immediately, except the ones added
+// with AddRefreshed, and then on its interval until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, j := range s.jobs {
+		wg.Add(1)
+		go func(j job) {
+			defer wg.Done()
+			s.run(ctx, j)
+		}(j)
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) run(ctx context.Context, j job) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if !j.refreshed {
+			s.update(ctx, j)
+		}
+		j.refreshed = false
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) update(ctx context.Context, j job) {
+	start := time.Now()
+	err := j.updater.Update(ctx)
+	duration := time.Since(start)
+
+	s.duration.WithLabelValues(j.name).Set(duration.Seconds())
+	if err != nil {
+		s.up.WithLabelValues(j.name).Set(0)
+		log.Printf("Error refreshing %s: %v", j.name, err)
+		return
+	}
+
+	s.up.WithLabelValues(j.name).Set(1)
+	s.lastSuccess.WithLabelValues(j.name).SetToCurrentTime()
+	log.Printf("Refreshed %s in %s", j.name, duration.Round(time.Millisecond))
+}
+
+// metricCache holds the metrics produced by the last refresh of a collector
+type metricCache struct {
+	mu      sync.RWMutex
+	metrics []prometheus.Metric
+}
+
+// set replaces the cached metrics
+func (c *metricCache) set(metrics []prometheus.Metric) {
+	c.mu.Lock()
+	c.metrics = metrics
+	c.mu.Unlock()
+}
+
+// setError replaces the cached metrics with an invalid metric for each
+// metric family of the collector. A failed refresh never leaves stale or
+// placeholder values behind: the error is reported on scrape instead.
+func (c *metricCache) setError(collector prometheus.Collector, err error) {
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		collector.Describe(descs)
+		close(descs)
+	}()
+
+	var metrics []prometheus.Metric
+	for desc := range descs {
+		metrics = append(metrics, prometheus.NewInvalidMetric(desc, err))
+	}
+	c.set(metrics)
+}
+
+// collect sends the cached metrics to the channel
+func (c *metricCache) collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
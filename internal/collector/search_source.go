@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// countOnlyStatuses are never downloaded by the SearchSource, as they hold
+// too many tickets. They are only counted by the recent_tickets collector.
+var countOnlyStatuses = []string{"closed"}
+
+// SearchSource is a TicketSource backed by the Search API
+type SearchSource struct {
+	client    *zendesk.Client
+	statuses  []string
+	tracker   *StatusTracker
+	last      map[string][]zendesk.Ticket // status -> tickets of the last successful search
+	fetched   *prometheus.CounterVec
+	splits    *prometheus.CounterVec
+	truncated *prometheus.GaugeVec
+}
+
+// NewSearchSource creates a new SearchSource
+func NewSearchSource(client *zendesk.Client, statuses []string, tracker *StatusTracker) *SearchSource {
+	return &SearchSource{
+		client:   client,
+		statuses: statuses,
+		tracker:  tracker,
+		last:     make(map[string][]zendesk.Ticket),
+		fetched:  newFetchedCounter(),
+		splits: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "zendesk_exporter_search_splits_total",
+				Help: "Number of times a ticket search was split into smaller time ranges to fit the Search API result limit",
+			},
+			[]string{"status"},
+		),
+		truncated: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "zendesk_exporter_search_truncated",
+				Help: "Whether the last ticket search for a status hit the Search API result limit and returned incomplete results",
+			},
+			[]string{"status"},
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (s *SearchSource) Describe(ch chan<- *prometheus.Desc) {
+	s.fetched.Describe(ch)
+	s.splits.Describe(ch)
+	s.truncated.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (s *SearchSource) Collect(ch chan<- prometheus.Metric) {
+	s.fetched.Collect(ch)
+	s.splits.Collect(ch)
+	s.truncated.Collect(ch)
+}
+
+// Fetch implements TicketSource
+func (s *SearchSource) Fetch(ctx context.Context, since time.Time) (map[string][]zendesk.Ticket, error) {
+	var statuses []string
+	for _, status := range s.statuses {
+		if !slices.Contains(countOnlyStatuses, status) {
+			statuses = append(statuses, status)
+		}
+	}
+
+	tickets := make(map[string][]zendesk.Ticket)
+	failed := make(map[string]error)
+	err := SearchByStatus(ctx, s.client, statuses, since, func(result SearchResult) error {
+		s.tracker.report(SnapshotName, result.Status, result.Error)
+		if result.Error != nil {
+			log.Printf("Error searching tickets for status %s: %v", result.Status, result.Error)
+			failed[result.Status] = result.Error
+			if last, ok := s.last[result.Status]; ok && s.tracker.serveStale() {
+				tickets[result.Status] = last
+			}
+			return nil
+		}
+
+		tickets[result.Status] = result.Items
+		s.last[result.Status] = result.Items
+		s.fetched.WithLabelValues(result.Status).Add(float64(len(result.Items)))
+
+		s.splits.WithLabelValues(result.Status).Add(float64(result.Splits))
+		truncated := 0.0
+		if result.Truncated {
+			truncated = 1
+		}
+		s.truncated.WithLabelValues(result.Status).Set(truncated)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tracker.check(failed, len(statuses)); err != nil {
+		return nil, err
+	}
+
+	return tickets, nil
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/nsxbet/zendesk_exporter/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatusTracker reports the outcome of the last fetch of each status and
+// holds the policy applied when a single status fails
+type StatusTracker struct {
+	policy string
+
+	up          *prometheus.GaugeVec
+	lastSuccess *prometheus.GaugeVec
+}
+
+// NewStatusTracker creates a new StatusTracker with one of the
+// config.StatusError* policies
+func NewStatusTracker(policy string) *StatusTracker {
+	return &StatusTracker{
+		policy: policy,
+		up: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "zendesk_exporter_status_up",
+				Help: "Whether the last fetch of the status succeeded",
+			},
+			[]string{"collector", "status"},
+		),
+		lastSuccess: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "zendesk_exporter_status_last_success_timestamp_seconds",
+				Help: "Unix timestamp of the last successful fetch of the status",
+			},
+			[]string{"collector", "status"},
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (t *StatusTracker) Describe(ch chan<- *prometheus.Desc) {
+	t.up.Describe(ch)
+	t.lastSuccess.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (t *StatusTracker) Collect(ch chan<- prometheus.Metric) {
+	t.up.Collect(ch)
+	t.lastSuccess.Collect(ch)
+}
+
+// report records the outcome of fetching a status for a collector
+func (t *StatusTracker) report(collector, status string, err error) {
+	if err != nil {
+		t.up.WithLabelValues(collector, status).Set(0)
+		return
+	}
+	t.up.WithLabelValues(collector, status).Set(1)
+	t.lastSuccess.WithLabelValues(collector, status).SetToCurrentTime()
+}
+
+// serveStale reports whether the last good value of a failed status is kept
+func (t *StatusTracker) serveStale() bool {
+	return t.policy == config.StatusErrorStale
+}
+
+// check returns the error failing the whole refresh, if any, given the
+// errors of the failed statuses out of all the statuses fetched. The refresh
+// always fails when every status failed, whatever the policy.
+func (t *StatusTracker) check(failed map[string]error, statuses int) error {
+	if len(failed) == 0 || (t.policy != config.StatusErrorFail && len(failed) < statuses) {
+		return nil
+	}
+
+	errs := make([]error, 0, len(failed))
+	for _, status := range slices.Sorted(maps.Keys(failed)) {
+		errs = append(errs, fmt.Errorf("status %s: %w", status, failed[status]))
+	}
+	return errors.Join(errs...)
+}
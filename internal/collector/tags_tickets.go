@@ -1,36 +1,42 @@
 package collector
 
 import (
-	"context"
-	"fmt"
 	"log"
-	"sync"
-	"time"
 
-	"github.com/nukosuke/go-zendesk/zendesk"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-// TagsTicketsCollector collects ticket tag metrics for the last 30 days
+func init() {
+	registerCollector("tags_tickets", true, func(cfg *Config) (prometheus.Collector, error) {
+		c := NewTagsTicketsCollector(NewValueFilter(cfg.Labels.Tag), cfg.Limiter)
+		cfg.Tickets.Subscribe(c)
+		return c, nil
+	})
+}
+
+// TagsTicketsCollector collects ticket tag metrics for each window
 type TagsTicketsCollector struct {
-	client *zendesk.Client
-	tags   *prometheus.Desc
-	total  *prometheus.Desc
+	filter  *ValueFilter
+	limiter *SeriesLimiter
+	tags    *prometheus.Desc
+	total   *prometheus.Desc
+	cache   metricCache
 }
 
 // NewTagsTicketsCollector creates a new TagsTicketsCollector
-func NewTagsTicketsCollector(client *zendesk.Client) *TagsTicketsCollector {
+func NewTagsTicketsCollector(filter *ValueFilter, limiter *SeriesLimiter) *TagsTicketsCollector {
 	return &TagsTicketsCollector{
-		client: client,
+		filter:  filter,
+		limiter: limiter,
 		tags: prometheus.NewDesc(
 			"zendesk_tickets_tags_count",
-			"Number of tickets by tag and status created in the last 30 days",
-			[]string{"tag", "status"}, nil,
+			"Number of tickets by tag and status created within the window",
+			[]string{"tag", "status", "window"}, nil,
 		),
 		total: prometheus.NewDesc(
 			"zendesk_tickets_tags_total",
-			"Total number of tickets with tags in the last 30 days",
-			[]string{"status"}, nil,
+			"Total number of tickets with tags created within the window",
+			[]string{"status", "window"}, nil,
 		),
 	}
 }
@@ -43,78 +49,83 @@ func (c *TagsTicketsCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements prometheus.Collector
 func (c *TagsTicketsCollector) Collect(ch chan<- prometheus.Metric) {
-	ctx := context.Background()
+	c.cache.collect(ch)
+}
 
-	now := time.Now()
-	thirtyDaysAgo := now.AddDate(0, 0, -30)
-	timeRange := fmt.Sprintf("created>%s", thirtyDaysAgo.Format("2006-01-02"))
+// TicketsFailed implements TicketConsumer
+func (c *TagsTicketsCollector) TicketsFailed(err error) {
+	c.cache.setError(c, err)
+}
 
+// UpdateTickets implements TicketConsumer
+func (c *TagsTicketsCollector) UpdateTickets(snapshot *TicketSnapshot) {
 	type statusMetrics struct {
 		tags  map[string]float64
 		total int64
 	}
-	metrics := make(map[string]*statusMetrics)
-	var mu sync.Mutex
 
-	// Process tickets for each status
-	err := SearchByStatus(ctx, c.client, timeRange, func(status string, tickets []zendesk.Ticket) error {
-		statusTags := make(map[string]float64)
-		var statusTotal int64
+	var out []prometheus.Metric
+	var samples []sample
 
-		for _, ticket := range tickets {
-			if len(ticket.Tags) > 0 {
+	for _, window := range snapshot.Windows {
+		metrics := make(map[string]*statusMetrics)
+
+		// Process tickets for each status
+		for status, tickets := range snapshot.InWindow(window) {
+			statusTags := make(map[string]float64)
+			var statusTotal int64
+
+			for _, ticket := range tickets {
+				hasTag := false
 				for _, tag := range ticket.Tags {
-					statusTags[tag]++
+					if c.filter.Keep(tag) {
+						statusTags[tag]++
+						hasTag = true
+					}
+				}
+				if hasTag {
+					statusTotal++
 				}
-				statusTotal++
 			}
-		}
 
-		mu.Lock()
-		metrics[status] = &statusMetrics{
-			tags:  statusTags,
-			total: statusTotal,
+			metrics[status] = &statusMetrics{
+				tags:  c.filter.group(statusTags),
+				total: statusTotal,
+			}
 		}
-		mu.Unlock()
-
-		return nil
-	})
-
-	if err != nil {
-		log.Printf("Error collecting metrics: %v", err)
-		return
-	}
 
-	// Send all metrics at once
-	for status, statusMetric := range metrics {
-		// Send total tickets with tags for this status
-		ch <- prometheus.MustNewConstMetric(
-			c.total,
-			prometheus.GaugeValue,
-			float64(statusMetric.total),
-			status,
-		)
-
-		// Send metrics for each tag in this status
-		for tag, count := range statusMetric.tags {
-			ch <- prometheus.MustNewConstMetric(
-				c.tags,
+		// Build all metrics at once
+		for status, statusMetric := range metrics {
+			// Add total tickets with tags for this status
+			out = append(out, prometheus.MustNewConstMetric(
+				c.total,
 				prometheus.GaugeValue,
-				count,
-				tag,
+				float64(statusMetric.total),
 				status,
-			)
+				window.Name,
+			))
+
+			// Add metrics for each tag in this status
+			for tag, count := range statusMetric.tags {
+				samples = append(samples, sample{
+					labels: []string{tag, status, window.Name},
+					value:  count,
+				})
+			}
 		}
-	}
 
-	// Log summary
-	var totalTagged int64
-	uniqueTags := make(map[string]bool)
-	for _, sm := range metrics {
-		totalTagged += sm.total
-		for tag := range sm.tags {
-			uniqueTags[tag] = true
+		// Log summary
+		var totalTagged int64
+		uniqueTags := make(map[string]bool)
+		for _, sm := range metrics {
+			totalTagged += sm.total
+			for tag := range sm.tags {
+				uniqueTags[tag] = true
+			}
 		}
+		log.Printf("Collected tickets with tags in window %s: %d, unique tags: %d", window.Name, totalTagged, len(uniqueTags))
 	}
-	log.Printf("Collected tickets with tags: %d, unique tags: %d", totalTagged, len(uniqueTags))
+
+	out = append(out, c.limiter.metrics("zendesk_tickets_tags_count", c.tags, samples)...)
+	c.cache.set(out)
 }
@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nsxbet/zendesk_exporter/internal/config"
+
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var throughputRefreshInterval = registerRefreshInterval("throughput", "5m")
+
+// throughputEvents are the ticket dates counted within each window, named
+// after their search keyword
+var throughputEvents = []string{"created", "solved", "updated"}
+
+func init() {
+	registerCollector("throughput", true, func(cfg *Config) (prometheus.Collector, error) {
+		c := NewThroughputCollector(cfg.Client, cfg.Windows)
+		cfg.Scheduler.Add("throughput", c, cfg.refreshInterval("throughput", *throughputRefreshInterval))
+		return c, nil
+	})
+}
+
+// ThroughputCollector collects the number of tickets created, solved and
+// updated within each window using the search count API
+type ThroughputCollector struct {
+	client  *zendesk.Client
+	windows []config.Window
+	count   *prometheus.Desc
+	netFlow *prometheus.Desc
+	cache   metricCache
+}
+
+// NewThroughputCollector creates a new ThroughputCollector
+func NewThroughputCollector(client *zendesk.Client, windows []config.Window) *ThroughputCollector {
+	return &ThroughputCollector{
+		client:  client,
+		windows: windows,
+		count: prometheus.NewDesc(
+			"zendesk_tickets_throughput_count",
+			"Number of tickets created, solved or updated within the window",
+			[]string{"event", "window"}, nil,
+		),
+		netFlow: prometheus.NewDesc(
+			"zendesk_tickets_net_flow",
+			"Number of tickets created minus the number of tickets solved within the window",
+			[]string{"window"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *ThroughputCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.count
+	ch <- c.netFlow
+}
+
+// Collect implements prometheus.Collector
+func (c *ThroughputCollector) Collect(ch chan<- prometheus.Metric) {
+	c.cache.collect(ch)
+}
+
+// Update implements Updater
+func (c *ThroughputCollector) Update(ctx context.Context) error {
+	now := time.Now()
+
+	var out []prometheus.Metric
+
+	for _, window := range c.windows {
+		start := window.Start(now).UTC().Format(time.RFC3339)
+		counts := make(map[string]int)
+
+		// Count tickets for each event
+		for _, event := range throughputEvents {
+			count, err := c.client.SearchCount(ctx, &zendesk.CountOptions{
+				Query: fmt.Sprintf("%s>=%s type:ticket", event, start),
+			})
+			if err != nil {
+				err = fmt.Errorf("error counting tickets %s in window %s: %w", event, window.Name, err)
+				c.cache.setError(c, err)
+				return err
+			}
+
+			counts[event] = count
+			out = append(out, prometheus.MustNewConstMetric(
+				c.count,
+				prometheus.GaugeValue,
+				float64(count),
+				event,
+				window.Name,
+			))
+		}
+
+		out = append(out, prometheus.MustNewConstMetric(
+			c.netFlow,
+			prometheus.GaugeValue,
+			float64(counts["created"]-counts["solved"]),
+			window.Name,
+		))
+
+		log.Printf("Collected throughput in window %s: %v", window.Name, counts)
+	}
+
+	c.cache.set(out)
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// TicketFieldCache holds the ticket field definitions, which give the title
+// and type of the custom fields of tickets. It is refreshed periodically so
+// fields edited by admins are picked up.
+type TicketFieldCache struct {
+	client *zendesk.Client
+
+	mu      sync.RWMutex
+	fields  map[int64]zendesk.TicketField // field ID -> definition
+	options map[int64]map[string]string   // field ID -> option value -> option name
+}
+
+// NewTicketFieldCache creates a new TicketFieldCache
+func NewTicketFieldCache(client *zendesk.Client) *TicketFieldCache {
+	return &TicketFieldCache{
+		client:  client,
+		fields:  make(map[int64]zendesk.TicketField),
+		options: make(map[int64]map[string]string),
+	}
+}
+
+// Update implements Updater
+func (c *TicketFieldCache) Update(ctx context.Context) error {
+	fields := make(map[int64]zendesk.TicketField)
+	options := make(map[int64]map[string]string)
+
+	it := c.client.GetTicketFieldsIterator(ctx, zendesk.NewPaginationOptions())
+	for it.HasMore() {
+		page, err := it.GetNext()
+		if err != nil {
+			return fmt.Errorf("error fetching ticket fields: %w", err)
+		}
+		for _, field := range page {
+			fields[field.ID] = field
+			if len(field.CustomFieldOptions) > 0 {
+				options[field.ID] = make(map[string]string, len(field.CustomFieldOptions))
+				for _, option := range field.CustomFieldOptions {
+					options[field.ID][option.Value] = option.Name
+				}
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.fields = fields
+	c.options = options
+	c.mu.Unlock()
+
+	log.Printf("Fetched %d ticket fields", len(fields))
+	return nil
+}
+
+// Get returns the definition of a field
+func (c *TicketFieldCache) Get(id int64) (zendesk.TicketField, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	field, ok := c.fields[id]
+	return field, ok
+}
+
+// Title returns the title of a field, or an empty string when the field is unknown
+func (c *TicketFieldCache) Title(id int64) string {
+	field, _ := c.Get(id)
+	return field.Title
+}
+
+// OptionName returns the display name of an option of a dropdown field, or
+// an empty string when the field has no such option
+func (c *TicketFieldCache) OptionName(id int64, value string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.options[id][value]
+}
+
+// Type returns the type of a field, or an empty string when the field is unknown
+func (c *TicketFieldCache) Type(id int64) string {
+	field, _ := c.Get(id)
+	return field.Type
+}
+
+// Values returns the label values of a custom field of a ticket, based on
+// the type of the field. Multi-select fields have one value per selected
+// option. Checkbox and date fields have none, as they are exported by their
+// own metrics.
+func (c *TicketFieldCache) Values(field zendesk.CustomField) []string {
+	switch value := field.Value.(type) {
+	case nil, bool:
+		return nil
+	case string:
+		if value == "" || c.Type(field.ID) == "date" {
+			return nil
+		}
+		return []string{value}
+	case []interface{}:
+		var values []string
+		for _, option := range value {
+			if s, ok := option.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	case []string:
+		var values []string
+		for _, option := range value {
+			if option != "" {
+				values = append(values, option)
+			}
+		}
+		return values
+	default:
+		return []string{fmt.Sprintf("%v", value)}
+	}
+}
+
+// checkboxValue returns the value of a checkbox field, and whether the field
+// is a checkbox
+func checkboxValue(field zendesk.CustomField) (checked, ok bool) {
+	checked, ok = field.Value.(bool)
+	return checked, ok
+}
+
+// Date returns the value of a date field, and whether the field is a date
+// field with a value
+func (c *TicketFieldCache) Date(field zendesk.CustomField) (time.Time, bool) {
+	value, ok := field.Value.(string)
+	if !ok || value == "" || c.Type(field.ID) != "date" {
+		return time.Time{}, false
+	}
+
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
@@ -0,0 +1,191 @@
+package collector
+
+import (
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("ticket_metrics", false, func(cfg *Config) (prometheus.Collector, error) {
+		if err := cfg.Tickets.IncludeMetricSets(); err != nil {
+			return nil, err
+		}
+		c := NewTicketMetricsCollector(cfg.Groups, cfg.TicketMetrics.Buckets)
+		cfg.Tickets.Subscribe(c)
+		return c, nil
+	})
+}
+
+// ticketMetric is a duration of the ticket metrics exported as a histogram
+type ticketMetric struct {
+	desc  *prometheus.Desc
+	value func(m TicketMetricSet) MetricDurations
+}
+
+// TicketMetricsCollector collects histograms of the reply, resolution and
+// wait times of the tickets created within each window
+type TicketMetricsCollector struct {
+	groups  *GroupCache
+	buckets []float64
+	metrics []ticketMetric
+	cache   metricCache
+}
+
+// NewTicketMetricsCollector creates a new TicketMetricsCollector with
+// buckets in minutes
+func NewTicketMetricsCollector(groups *GroupCache, buckets []float64) *TicketMetricsCollector {
+	newMetric := func(name, help string, value func(m TicketMetricSet) MetricDurations) ticketMetric {
+		return ticketMetric{
+			desc: prometheus.NewDesc(
+				name,
+				help,
+				[]string{"time", "channel", "priority", "group", "type", "window"}, nil,
+			),
+			value: value,
+		}
+	}
+
+	return &TicketMetricsCollector{
+		groups:  groups,
+		buckets: buckets,
+		metrics: []ticketMetric{
+			newMetric(
+				"zendesk_tickets_first_reply_time_minutes",
+				"Time to the first public agent reply of tickets created within the window",
+				func(m TicketMetricSet) MetricDurations { return m.ReplyTime },
+			),
+			newMetric(
+				"zendesk_tickets_first_resolution_time_minutes",
+				"Time to the first resolution of tickets created within the window",
+				func(m TicketMetricSet) MetricDurations { return m.FirstResolutionTime },
+			),
+			newMetric(
+				"zendesk_tickets_full_resolution_time_minutes",
+				"Time to the last resolution of tickets created within the window",
+				func(m TicketMetricSet) MetricDurations { return m.FullResolutionTime },
+			),
+			newMetric(
+				"zendesk_tickets_requester_wait_time_minutes",
+				"Time spent waiting for an agent by the requester of tickets created within the window",
+				func(m TicketMetricSet) MetricDurations { return m.RequesterWaitTime },
+			),
+			newMetric(
+				"zendesk_tickets_agent_wait_time_minutes",
+				"Time spent waiting for the requester by the agents of tickets created within the window",
+				func(m TicketMetricSet) MetricDurations { return m.AgentWaitTime },
+			),
+			newMetric(
+				"zendesk_tickets_on_hold_time_minutes",
+				"Time spent on hold by tickets created within the window",
+				func(m TicketMetricSet) MetricDurations { return m.OnHoldTime },
+			),
+		},
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *TicketMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, metric := range c.metrics {
+		ch <- metric.desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *TicketMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.cache.collect(ch)
+}
+
+// TicketsFailed implements TicketConsumer
+func (c *TicketMetricsCollector) TicketsFailed(err error) {
+	c.cache.setError(c, err)
+}
+
+// ticketMetricLabels identifies a ticket metric series within a window
+type ticketMetricLabels struct {
+	time       string // calendar or business
+	channel    string
+	priority   string
+	group      string
+	ticketType string
+}
+
+// UpdateTickets implements TicketConsumer
+func (c *TicketMetricsCollector) UpdateTickets(snapshot *TicketSnapshot) {
+	var out []prometheus.Metric
+
+	for _, window := range snapshot.Windows {
+		observations := make([]map[ticketMetricLabels][]float64, len(c.metrics))
+		for i := range observations {
+			observations[i] = make(map[ticketMetricLabels][]float64)
+		}
+
+		var withMetrics int
+		for _, tickets := range snapshot.InWindow(window) {
+			for _, ticket := range tickets {
+				metricSet, ok := snapshot.MetricSets[ticket.ID]
+				if !ok {
+					continue
+				}
+				withMetrics++
+
+				channel := "unknown"
+				if ticket.Via != nil {
+					channel = ticket.Via.Channel
+				}
+
+				priority := ticket.Priority
+				if priority == "" {
+					priority = "none"
+				}
+
+				ticketType := ticket.Type
+				if ticketType == "" {
+					ticketType = "none"
+				}
+
+				groupID, _ := ticket.GroupID.Int64()
+				labels := ticketMetricLabels{
+					channel:    channel,
+					priority:   priority,
+					group:      c.groups.Name(groupID),
+					ticketType: ticketType,
+				}
+
+				for i, metric := range c.metrics {
+					durations := metric.value(metricSet)
+					if durations.Calendar != nil {
+						labels.time = "calendar"
+						observations[i][labels] = append(observations[i][labels], *durations.Calendar)
+					}
+					if durations.Business != nil {
+						labels.time = "business"
+						observations[i][labels] = append(observations[i][labels], *durations.Business)
+					}
+				}
+			}
+		}
+
+		for i, metric := range c.metrics {
+			for labels, values := range observations[i] {
+				count, sum, buckets := histogram(values, c.buckets)
+				out = append(out, prometheus.MustNewConstHistogram(
+					metric.desc,
+					count,
+					sum,
+					buckets,
+					labels.time,
+					labels.channel,
+					labels.priority,
+					labels.group,
+					labels.ticketType,
+					window.Name,
+				))
+			}
+		}
+
+		log.Printf("Collected ticket metrics of %d tickets in window %s", withMetrics, window.Name)
+	}
+
+	c.cache.set(out)
+}
@@ -0,0 +1,169 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nsxbet/zendesk_exporter/internal/config"
+
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SnapshotName is the name the shared ticket snapshot is reported under
+const SnapshotName = "tickets_snapshot"
+
+// TicketSnapshot holds the tickets fetched in a single refresh cycle
+type TicketSnapshot struct {
+	Tickets    map[string][]zendesk.Ticket // status -> tickets
+	MetricSets map[int64]TicketMetricSet   // ticket ID -> metrics, when the source provides them
+	Windows    []config.Window
+	FetchedAt  time.Time
+}
+
+// InWindow returns the tickets created within a window, grouped by status
+func (s *TicketSnapshot) InWindow(window config.Window) map[string][]zendesk.Ticket {
+	start := window.Start(s.FetchedAt)
+
+	tickets := make(map[string][]zendesk.Ticket, len(s.Tickets))
+	for status, statusTickets := range s.Tickets {
+		var inWindow []zendesk.Ticket
+		for _, ticket := range statusTickets {
+			if ticket.CreatedAt != nil && !ticket.CreatedAt.Before(start) {
+				inWindow = append(inWindow, ticket)
+			}
+		}
+		tickets[status] = inWindow
+	}
+	return tickets
+}
+
+// TicketConsumer is implemented by collectors that derive their metrics from
+// the shared ticket snapshot
+type TicketConsumer interface {
+	UpdateTickets(snapshot *TicketSnapshot)
+	TicketsFailed(err error)
+}
+
+// TicketSource retrieves the tickets created since a given time, grouped by status
+type TicketSource interface {
+	Fetch(ctx context.Context, since time.Time) (map[string][]zendesk.Ticket, error)
+}
+
+// MetricSetSource is implemented by the ticket sources that can fetch the
+// metrics of the tickets along with them
+type MetricSetSource interface {
+	IncludeMetricSets()
+	MetricSets() map[int64]TicketMetricSet
+}
+
+// TicketMetricSet holds the metrics of a ticket, in minutes. Durations are
+// nil until they are known, such as the reply time of an unanswered ticket.
+type TicketMetricSet struct {
+	TicketID            int64           `json:"ticket_id"`
+	ReplyTime           MetricDurations `json:"reply_time_in_minutes"`
+	FirstResolutionTime MetricDurations `json:"first_resolution_time_in_minutes"`
+	FullResolutionTime  MetricDurations `json:"full_resolution_time_in_minutes"`
+	RequesterWaitTime   MetricDurations `json:"requester_wait_time_in_minutes"`
+	AgentWaitTime       MetricDurations `json:"agent_wait_time_in_minutes"`
+	OnHoldTime          MetricDurations `json:"on_hold_time_in_minutes"`
+}
+
+// MetricDurations is a ticket metric in calendar and business minutes
+type MetricDurations struct {
+	Calendar *float64 `json:"calendar"`
+	Business *float64 `json:"business"`
+}
+
+// newFetchedCounter creates the counter of tickets downloaded by a TicketSource
+func newFetchedCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "zendesk_exporter_tickets_fetched_total",
+			Help: "Number of tickets downloaded from the Zendesk API by status",
+		},
+		[]string{"status"},
+	)
+}
+
+// TicketStore fetches the tickets created within the longest window once
+// per refresh and fans the snapshot out to every consumer
+type TicketStore struct {
+	source    TicketSource
+	windows   []config.Window
+	consumers []TicketConsumer
+}
+
+// NewTicketStore creates a new TicketStore
+func NewTicketStore(source TicketSource, windows []config.Window) *TicketStore {
+	return &TicketStore{
+		source:  source,
+		windows: windows,
+	}
+}
+
+// Subscribe adds a consumer that receives every new snapshot
+func (s *TicketStore) Subscribe(consumer TicketConsumer) {
+	s.consumers = append(s.consumers, consumer)
+}
+
+// IncludeMetricSets makes the snapshot include the metrics of the tickets. It
+// fails when the source can't fetch them.
+func (s *TicketStore) IncludeMetricSets() error {
+	source, ok := s.source.(MetricSetSource)
+	if !ok {
+		return errors.New("ticket metrics require the incremental ticket source")
+	}
+	source.IncludeMetricSets()
+	return nil
+}
+
+// HasConsumers reports whether any consumer is subscribed
+func (s *TicketStore) HasConsumers() bool {
+	return len(s.consumers) > 0
+}
+
+// Update implements Updater
+func (s *TicketStore) Update(ctx context.Context) error {
+	now := time.Now()
+
+	since := now
+	for _, window := range s.windows {
+		if start := window.Start(now); start.Before(since) {
+			since = start
+		}
+	}
+
+	tickets, err := s.source.Fetch(ctx, since)
+	if err != nil {
+		err = fmt.Errorf("error fetching tickets: %w", err)
+		for _, consumer := range s.consumers {
+			consumer.TicketsFailed(err)
+		}
+		return err
+	}
+
+	snapshot := &TicketSnapshot{
+		Tickets:   tickets,
+		Windows:   s.windows,
+		FetchedAt: now,
+	}
+	if source, ok := s.source.(MetricSetSource); ok {
+		snapshot.MetricSets = source.MetricSets()
+	}
+
+	var total int
+	for _, tickets := range snapshot.Tickets {
+		total += len(tickets)
+	}
+	log.Printf("Fetched %d tickets for %d consumers", total, len(s.consumers))
+
+	for _, consumer := range s.consumers {
+		consumer.UpdateTickets(snapshot)
+	}
+
+	return nil
+}
@@ -1,36 +1,69 @@
 package collector
 
 import (
-	"context"
-	"fmt"
 	"log"
-	"sync"
-	"time"
+	"slices"
+	"strings"
+
+	"github.com/nsxbet/zendesk_exporter/internal/config"
 
 	"github.com/nukosuke/go-zendesk/zendesk"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-// TicketsCollector collects detailed ticket metrics for the last 30 days
+func init() {
+	registerCollector("tickets", true, func(cfg *Config) (prometheus.Collector, error) {
+		c := NewTicketsCollector(cfg.Labels.Dimensions, cfg.Labels.DimensionGroups, cfg.Fields, NewValueFilter(cfg.Labels.Tag), NewValueFilter(cfg.Labels.CustomField), cfg.Limiter)
+		cfg.Tickets.Subscribe(c)
+		return c, nil
+	})
+}
+
+// TicketsCollector collects detailed ticket metrics for each window
 type TicketsCollector struct {
-	client  *zendesk.Client
-	tickets *prometheus.Desc
-	total   *prometheus.Desc
+	dimensions        []string
+	dimensionGroups   [][]string
+	fields            *TicketFieldCache
+	tagFilter         *ValueFilter
+	customFieldFilter *ValueFilter
+	limiter           *SeriesLimiter
+	tickets           *prometheus.Desc
+	grouped           *prometheus.Desc
+	total             *prometheus.Desc
+	cache             metricCache
 }
 
-// NewTicketsCollector creates a new TicketsCollector
-func NewTicketsCollector(client *zendesk.Client) *TicketsCollector {
+// NewTicketsCollector creates a new TicketsCollector. The dimensions are the
+// labels of zendesk_tickets_count besides status and window, and each
+// dimension group is counted separately in zendesk_tickets_dimension_count.
+func NewTicketsCollector(dimensions []string, dimensionGroups [][]string, fields *TicketFieldCache, tagFilter, customFieldFilter *ValueFilter, limiter *SeriesLimiter) *TicketsCollector {
+	labels := append([]string{"status"}, dimensions...)
+	labels = append(labels, "window")
+
+	groupedLabels := append([]string{"status", "dimension_group"}, config.TicketDimensions...)
+	groupedLabels = append(groupedLabels, "window")
+
 	return &TicketsCollector{
-		client: client,
+		dimensions:        dimensions,
+		dimensionGroups:   dimensionGroups,
+		fields:            fields,
+		tagFilter:         tagFilter,
+		customFieldFilter: customFieldFilter,
+		limiter:           limiter,
 		tickets: prometheus.NewDesc(
 			"zendesk_tickets_count",
-			"Number of tickets by status, priority, channel, type, tag, and custom field created in the last 30 days",
-			[]string{"status", "priority", "channel", "type", "tag", "custom_field"}, nil,
+			"Number of tickets by status and the configured dimensions created within the window",
+			labels, nil,
+		),
+		grouped: prometheus.NewDesc(
+			"zendesk_tickets_dimension_count",
+			"Number of tickets by status and the dimensions of each configured group created within the window",
+			groupedLabels, nil,
 		),
 		total: prometheus.NewDesc(
 			"zendesk_tickets_total",
-			"Total number of tickets by status created in the last 30 days",
-			[]string{"status"}, nil,
+			"Total number of tickets by status created within the window",
+			[]string{"status", "window"}, nil,
 		),
 	}
 }
@@ -38,168 +71,208 @@ func NewTicketsCollector(client *zendesk.Client) *TicketsCollector {
 // Describe implements prometheus.Collector
 func (c *TicketsCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.tickets
+	ch <- c.grouped
 	ch <- c.total
 }
 
 // Collect implements prometheus.Collector
 func (c *TicketsCollector) Collect(ch chan<- prometheus.Metric) {
-	ctx := context.Background()
+	c.cache.collect(ch)
+}
 
-	now := time.Now()
-	thirtyDaysAgo := now.AddDate(0, 0, -30)
-	timeRange := fmt.Sprintf("created>%s", thirtyDaysAgo.Format("2006-01-02"))
+// TicketsFailed implements TicketConsumer
+func (c *TicketsCollector) TicketsFailed(err error) {
+	c.cache.setError(c, err)
+}
 
-	// Initialize counts map
-	counts := make(map[string]map[string]map[string]map[string]map[string]map[string]int) // status->priority->channel->type->tag->customfield->count
-	statusTotals := make(map[string]int)                                                  // status->total
-	var mu sync.Mutex
+// ticketLabels identifies a zendesk_tickets_count series within a window.
+// Labels that aren't exported are empty.
+type ticketLabels struct {
+	status      string
+	priority    string
+	channel     string
+	ticketType  string
+	tag         string
+	customField string
+}
 
-	// Process tickets for each status
-	err := SearchByStatus(ctx, c.client, timeRange, func(status string, tickets []zendesk.Ticket) error {
-		localCounts := make(map[string]map[string]map[string]map[string]map[string]map[string]int)
-		localTotal := 0
+// values returns the label values of the series, in the order of the
+// dimensions
+func (l ticketLabels) values(dimensions []string) []string {
+	var values []string
+	for _, dimension := range dimensions {
+		switch dimension {
+		case "priority":
+			values = append(values, l.priority)
+		case "channel":
+			values = append(values, l.channel)
+		case "type":
+			values = append(values, l.ticketType)
+		case "tag":
+			values = append(values, l.tag)
+		case "custom_field":
+			values = append(values, l.customField)
+		}
+	}
+	return values
+}
 
-		for _, ticket := range tickets {
-			localTotal++
+// UpdateTickets implements TicketConsumer
+func (c *TicketsCollector) UpdateTickets(snapshot *TicketSnapshot) {
+	var out []prometheus.Metric
+	var samples, groupedSamples []sample
 
-			channel := "unknown"
-			if ticket.Via != nil {
-				channel = ticket.Via.Channel
-			}
+	for _, window := range snapshot.Windows {
+		counts := make(map[ticketLabels]int)
+		groupCounts := make([]map[ticketLabels]int, len(c.dimensionGroups))
+		for i := range groupCounts {
+			groupCounts[i] = make(map[ticketLabels]int)
+		}
+		statusTotals := make(map[string]int) // status->total
 
-			priority := ticket.Priority
-			if priority == "" {
-				priority = "none"
-			}
+		// Process tickets for each status
+		for status, tickets := range snapshot.InWindow(window) {
+			statusTotals[status] += len(tickets)
 
-			ticketType := ticket.Type
-			if ticketType == "" {
-				ticketType = "none"
+			c.count(counts, status, tickets, c.dimensions)
+			for i, group := range c.dimensionGroups {
+				c.count(groupCounts[i], status, tickets, group)
 			}
+		}
 
-			// Process tags
-			tags := map[string]bool{"none": true}
-			for _, tag := range ticket.Tags {
-				if tag != "" {
-					tags[tag] = true
-					delete(tags, "none")
-				}
-			}
+		// Build total metrics first
+		var totalTickets int
+		for status, total := range statusTotals {
+			out = append(out, prometheus.MustNewConstMetric(
+				c.total,
+				prometheus.GaugeValue,
+				float64(total),
+				status,
+				window.Name,
+			))
+			totalTickets += total
+		}
 
-			// Process custom fields
-			customFields := map[string]bool{"none": true}
-			for _, field := range ticket.CustomFields {
-				if field.Value != nil {
-					value := fmt.Sprintf("%v", field.Value)
-					if value != "" && !isNumeric(value) {
-						customFields[value] = true
-						delete(customFields, "none")
-					}
-				}
+		// Build detailed metrics
+		for labels, count := range counts {
+			values := append([]string{labels.status}, labels.values(c.dimensions)...)
+			samples = append(samples, sample{
+				labels: append(values, window.Name),
+				value:  float64(count),
+			})
+		}
+		for i, group := range c.dimensionGroups {
+			for labels, count := range groupCounts[i] {
+				values := append([]string{labels.status, strings.Join(group, ",")}, labels.values(config.TicketDimensions)...)
+				groupedSamples = append(groupedSamples, sample{
+					labels: append(values, window.Name),
+					value:  float64(count),
+				})
 			}
+		}
 
-			// Initialize and increment counters
-			for tag := range tags {
-				for customField := range customFields {
-					if localCounts[status] == nil {
-						localCounts[status] = make(map[string]map[string]map[string]map[string]map[string]int)
-					}
-					if localCounts[status][priority] == nil {
-						localCounts[status][priority] = make(map[string]map[string]map[string]map[string]int)
-					}
-					if localCounts[status][priority][channel] == nil {
-						localCounts[status][priority][channel] = make(map[string]map[string]map[string]int)
-					}
-					if localCounts[status][priority][channel][ticketType] == nil {
-						localCounts[status][priority][channel][ticketType] = make(map[string]map[string]int)
-					}
-					if localCounts[status][priority][channel][ticketType][tag] == nil {
-						localCounts[status][priority][channel][ticketType][tag] = make(map[string]int)
-					}
-					localCounts[status][priority][channel][ticketType][tag][customField]++
-				}
+		log.Printf("Collected %d total tickets in window %s across %d detailed metrics", totalTickets, window.Name, len(counts))
+	}
+
+	out = append(out, c.limiter.metrics("zendesk_tickets_count", c.tickets, samples)...)
+	out = append(out, c.limiter.metrics("zendesk_tickets_dimension_count", c.grouped, groupedSamples)...)
+	c.cache.set(out)
+}
+
+// count adds the tickets of a status to the counts of a set of dimensions.
+// A ticket is counted once per combination of its tags and custom field
+// values, when both are dimensions.
+func (c *TicketsCollector) count(counts map[ticketLabels]int, status string, tickets []zendesk.Ticket, dimensions []string) {
+	hasTag := slices.Contains(dimensions, "tag")
+	hasCustomField := slices.Contains(dimensions, "custom_field")
+
+	// Collect the exported tags and custom field values of each ticket first,
+	// so the values beyond the top N of the status are known
+	ticketTags := make([][]string, len(tickets))
+	ticketFields := make([][]string, len(tickets))
+	tagCounts := make(map[string]float64)
+	fieldCounts := make(map[string]float64)
+	for i, ticket := range tickets {
+		for _, tag := range ticket.Tags {
+			if hasTag && tag != "" && c.tagFilter.Keep(tag) {
+				ticketTags[i] = append(ticketTags[i], tag)
+				tagCounts[tag]++
 			}
 		}
-
-		// Merge local counts into global counts
-		mu.Lock()
-		statusTotals[status] += localTotal
-		for status, priorityMap := range localCounts {
-			if counts[status] == nil {
-				counts[status] = make(map[string]map[string]map[string]map[string]map[string]int)
+		for _, field := range ticket.CustomFields {
+			if !hasCustomField {
+				break
 			}
-			for priority, channelMap := range priorityMap {
-				if counts[status][priority] == nil {
-					counts[status][priority] = make(map[string]map[string]map[string]map[string]int)
-				}
-				for channel, typeMap := range channelMap {
-					if counts[status][priority][channel] == nil {
-						counts[status][priority][channel] = make(map[string]map[string]map[string]int)
-					}
-					for ticketType, tagMap := range typeMap {
-						if counts[status][priority][channel][ticketType] == nil {
-							counts[status][priority][channel][ticketType] = make(map[string]map[string]int)
-						}
-						for tag, customFieldMap := range tagMap {
-							if counts[status][priority][channel][ticketType][tag] == nil {
-								counts[status][priority][channel][ticketType][tag] = make(map[string]int)
-							}
-							for customField, count := range customFieldMap {
-								counts[status][priority][channel][ticketType][tag][customField] += count
-							}
-						}
-					}
+			for _, value := range c.fields.Values(field) {
+				if c.customFieldFilter.Keep(value) {
+					ticketFields[i] = append(ticketFields[i], value)
+					fieldCounts[value]++
 				}
 			}
 		}
-		mu.Unlock()
+	}
+	topTags := c.tagFilter.top(tagCounts)
+	topFields := c.customFieldFilter.top(fieldCounts)
 
-		return nil
-	})
+	for i, ticket := range tickets {
+		channel := "unknown"
+		if ticket.Via != nil {
+			channel = ticket.Via.Channel
+		}
 
-	if err != nil {
-		log.Printf("Error collecting metrics: %v", err)
-		return
-	}
+		priority := ticket.Priority
+		if priority == "" {
+			priority = "none"
+		}
 
-	// Send total metrics first
-	var totalTickets int
-	for status, total := range statusTotals {
-		ch <- prometheus.MustNewConstMetric(
-			c.total,
-			prometheus.GaugeValue,
-			float64(total),
-			status,
-		)
-		totalTickets += total
-	}
+		ticketType := ticket.Type
+		if ticketType == "" {
+			ticketType = "none"
+		}
 
-	// Send detailed metrics
-	var totalDetailedMetrics int
-	for status, priorityMap := range counts {
-		for priority, channelMap := range priorityMap {
-			for channel, typeMap := range channelMap {
-				for ticketType, tagMap := range typeMap {
-					for tag, customFieldMap := range tagMap {
-						for customField, count := range customFieldMap {
-							ch <- prometheus.MustNewConstMetric(
-								c.tickets,
-								prometheus.GaugeValue,
-								float64(count),
-								status,
-								priority,
-								channel,
-								ticketType,
-								tag,
-								customField,
-							)
-							totalDetailedMetrics++
-						}
-					}
-				}
+		// Labels that aren't exported must not split the counts
+		if !slices.Contains(dimensions, "channel") {
+			channel = ""
+		}
+		if !slices.Contains(dimensions, "priority") {
+			priority = ""
+		}
+		if !slices.Contains(dimensions, "type") {
+			ticketType = ""
+		}
+
+		// Process tags
+		tags := map[string]bool{"": true}
+		if hasTag {
+			tags = map[string]bool{"none": true}
+		}
+		for _, tag := range ticketTags[i] {
+			tags[label(tag, topTags)] = true
+			delete(tags, "none")
+		}
+
+		// Process custom fields
+		customFields := map[string]bool{"": true}
+		if hasCustomField {
+			customFields = map[string]bool{"none": true}
+		}
+		for _, value := range ticketFields[i] {
+			customFields[label(value, topFields)] = true
+			delete(customFields, "none")
+		}
+
+		// Increment counters
+		for tag := range tags {
+			for customField := range customFields {
+				counts[ticketLabels{
+					status:      status,
+					priority:    priority,
+					channel:     channel,
+					ticketType:  ticketType,
+					tag:         tag,
+					customField: customField,
+				}]++
 			}
 		}
 	}
-
-	log.Printf("Collected %d total tickets across %d detailed metrics", totalTickets, totalDetailedMetrics)
 }
@@ -6,23 +6,45 @@ import (
 	"log"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/nukosuke/go-zendesk/zendesk"
 )
 
+// searchResultLimit is the maximum number of results the Search API returns for a query
+const searchResultLimit = 1000
+
+// minSearchWindow is the smallest time range a search is split into
+const minSearchWindow = time.Minute
+
 // SearchResult holds the result of a status-based search
 type SearchResult struct {
-	Status string
-	Items  []zendesk.Ticket
-	Error  error
+	Status    string
+	Items     []zendesk.Ticket
+	Splits    int  // number of times the time range was split to fit the result limit
+	Truncated bool // whether some results were dropped because of the result limit
+	Error     error
 }
 
-// StatusSearcher defines a function type that processes tickets for a specific status
-type StatusSearcher func(status string, tickets []zendesk.Ticket) error
+// StatusSearcher defines a function type that processes the search result
+// for a specific status. It is called for failed searches too, with Error set.
+type StatusSearcher func(result SearchResult) error
+
+// timeRange is a creation time range, including from and excluding to
+type timeRange struct {
+	from, to time.Time
+}
 
-// SearchByStatus performs a parallel search across all statuses and processes results
-func SearchByStatus(ctx context.Context, client *zendesk.Client, timeRange string, processor StatusSearcher) error {
-	statuses := []string{"new", "open", "pending", "solved"} // omit closed status, too many tickets
+// query returns the search query filtering tickets created in the range
+func (r timeRange) query() string {
+	return fmt.Sprintf("created>=%s created<%s", r.from.UTC().Format(time.RFC3339), r.to.UTC().Format(time.RFC3339))
+}
+
+// SearchByStatus performs a parallel search across statuses for tickets
+// created after since and processes results. A failed status doesn't stop
+// the other ones: the processor decides how to handle it.
+func SearchByStatus(ctx context.Context, client *zendesk.Client, statuses []string, since time.Time, processor StatusSearcher) error {
+	r := timeRange{from: since, to: time.Now()}
 	var wg sync.WaitGroup
 	resultChan := make(chan SearchResult, len(statuses))
 
@@ -32,12 +54,9 @@ func SearchByStatus(ctx context.Context, client *zendesk.Client, timeRange strin
 		go func(status string) {
 			defer wg.Done()
 
-			tickets, err := searchTickets(ctx, client, status, timeRange)
-			resultChan <- SearchResult{
-				Status: status,
-				Items:  tickets,
-				Error:  err,
-			}
+			result := SearchResult{Status: status}
+			result.Items, result.Error = searchTickets(ctx, client, status, r, &result)
+			resultChan <- result
 		}(status)
 	}
 
@@ -49,12 +68,8 @@ func SearchByStatus(ctx context.Context, client *zendesk.Client, timeRange strin
 
 	// Process results as they come in
 	for result := range resultChan {
-		if result.Error != nil {
-			log.Printf("Error searching tickets for status %s: %v", result.Status, result.Error)
-			continue
-		}
 		// Always call processor with status, even if no tickets found
-		if err := processor(result.Status, result.Items); err != nil {
+		if err := processor(result); err != nil {
 			return fmt.Errorf("error processing tickets for status %s: %w", result.Status, err)
 		}
 	}
@@ -62,9 +77,11 @@ func SearchByStatus(ctx context.Context, client *zendesk.Client, timeRange strin
 	return nil
 }
 
-// searchTickets performs the actual search for a specific status
-func searchTickets(ctx context.Context, client *zendesk.Client, status, timeRange string) ([]zendesk.Ticket, error) {
-	searchQuery := fmt.Sprintf("%s status:%s type:ticket", timeRange, status)
+// searchTickets performs the actual search for a specific status. When the
+// range holds more tickets than the Search API returns, it is split in two
+// and each half is searched separately.
+func searchTickets(ctx context.Context, client *zendesk.Client, status string, r timeRange, result *SearchResult) ([]zendesk.Ticket, error) {
+	searchQuery := fmt.Sprintf("%s status:%s type:ticket", r.query(), status)
 	opts := &zendesk.SearchOptions{
 		PageOptions: zendesk.PageOptions{
 			Page:    1,
@@ -81,13 +98,24 @@ func searchTickets(ctx context.Context, client *zendesk.Client, status, timeRang
 			return nil, err
 		}
 
+		if opts.Page == 1 && page.Count > searchResultLimit {
+			if r.to.Sub(r.from) > minSearchWindow {
+				result.Splits++
+				return bisectSearch(ctx, client, status, r, result)
+			}
+			log.Printf("Search for status %s between %s and %s is truncated: %d results, only %d returned",
+				status, r.from.Format(time.RFC3339), r.to.Format(time.RFC3339), page.Count, searchResultLimit)
+			result.Truncated = true
+		}
+
 		for _, item := range results.List() {
 			if ticket, ok := item.(zendesk.Ticket); ok {
 				tickets = append(tickets, ticket)
 			}
 		}
 
-		if !page.HasNext() {
+		// The Search API rejects pages beyond the result limit
+		if !page.HasNext() || opts.Page*opts.PerPage >= searchResultLimit {
 			break
 		}
 		opts.Page++
@@ -96,8 +124,46 @@ func searchTickets(ctx context.Context, client *zendesk.Client, status, timeRang
 	return tickets, nil
 }
 
+// bisectSearch searches both halves of a time range and merges the results
+func bisectSearch(ctx context.Context, client *zendesk.Client, status string, r timeRange, result *SearchResult) ([]zendesk.Ticket, error) {
+	mid := r.from.Add(r.to.Sub(r.from) / 2).Truncate(time.Second)
+
+	older, err := searchTickets(ctx, client, status, timeRange{from: r.from, to: mid}, result)
+	if err != nil {
+		return nil, err
+	}
+
+	newer, err := searchTickets(ctx, client, status, timeRange{from: mid, to: r.to}, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(older, newer...), nil
+}
+
 // isNumeric checks if a string represents a number
 func isNumeric(s string) bool {
 	_, err := strconv.ParseFloat(s, 64)
 	return err == nil
 }
+
+// histogram returns the count, sum and cumulative bucket counts of a set of
+// observations, as expected by prometheus.MustNewConstHistogram
+func histogram(observations []float64, bounds []float64) (uint64, float64, map[float64]uint64) {
+	var sum float64
+	buckets := make(map[float64]uint64, len(bounds))
+	for _, bound := range bounds {
+		buckets[bound] = 0
+	}
+
+	for _, observation := range observations {
+		sum += observation
+		for _, bound := range bounds {
+			if observation <= bound {
+				buckets[bound]++
+			}
+		}
+	}
+
+	return uint64(len(observations)), sum, buckets
+}
@@ -0,0 +1,453 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Statuses lists the ticket statuses that can be configured
+var Statuses = []string{"new", "open", "pending", "hold", "solved", "closed"}
+
+// TicketDimensions lists the labels of zendesk_tickets_count that can be
+// configured, besides status and window
+var TicketDimensions = []string{"priority", "channel", "type", "tag", "custom_field"}
+
+// Policies applied when the fetch of a single status fails
+const (
+	StatusErrorStale = "stale" // serve the last good value of the status
+	StatusErrorDrop  = "drop"  // drop the series of the status
+	StatusErrorFail  = "fail"  // fail the whole refresh
+)
+
+// Config is the exporter configuration
+type Config struct {
+	Zendesk    ZendeskConfig              `yaml:"zendesk"`
+	Tickets    TicketsConfig              `yaml:"tickets"`
+	Collectors map[string]CollectorConfig `yaml:"collectors"`
+	Labels     LabelsConfig               `yaml:"labels"`
+	Targets    map[string]TargetConfig    `yaml:"targets"`
+
+	NumericFields []NumericFieldConfig `yaml:"numeric_fields"`
+	TicketMetrics TicketMetricsConfig  `yaml:"ticket_metrics"`
+	Backlog       BacklogConfig        `yaml:"backlog"`
+}
+
+// ZendeskConfig holds the Zendesk API credentials and client settings
+type ZendeskConfig struct {
+	Subdomain   string `yaml:"subdomain"`
+	Credentials `yaml:",inline"`
+	MaxRetries  int `yaml:"max_retries"`
+}
+
+// TargetConfig holds the credentials of a Zendesk account served on
+// /probe, keyed by subdomain
+type TargetConfig struct {
+	Credentials `yaml:",inline"`
+}
+
+// Credentials authenticate to the Zendesk API with either an API token and
+// the email of its user or an OAuth access token. Secrets can be read from
+// files, which are reloaded when they change.
+type Credentials struct {
+	Email          string `yaml:"email"`
+	APIToken       string `yaml:"api_token"`
+	APITokenFile   string `yaml:"api_token_file"`
+	OAuthToken     string `yaml:"oauth_token"`
+	OAuthTokenFile string `yaml:"oauth_token_file"`
+}
+
+// hasSecret reports whether any secret is set
+func (c Credentials) hasSecret() bool {
+	return c.APIToken != "" || c.APITokenFile != "" || c.OAuthToken != "" || c.OAuthTokenFile != ""
+}
+
+// withoutSecrets returns the credentials with only the email set
+func (c Credentials) withoutSecrets() Credentials {
+	return Credentials{Email: c.Email}
+}
+
+// validate checks that exactly one secret is set
+func (c Credentials) validate() error {
+	var set []string
+	for _, secret := range []struct{ name, value string }{
+		{"api_token", c.APIToken},
+		{"api_token_file", c.APITokenFile},
+		{"oauth_token", c.OAuthToken},
+		{"oauth_token_file", c.OAuthTokenFile},
+	} {
+		if secret.value != "" {
+			set = append(set, secret.name)
+		}
+	}
+
+	if len(set) == 0 {
+		return errors.New("one of api_token, api_token_file, oauth_token or oauth_token_file must be set")
+	}
+	if len(set) > 1 {
+		return fmt.Errorf("only one of api_token, api_token_file, oauth_token or oauth_token_file can be set, got %s", strings.Join(set, ", "))
+	}
+	if (c.APIToken != "" || c.APITokenFile != "") && c.Email == "" {
+		return errors.New("email must be set with an API token")
+	}
+	return nil
+}
+
+// TicketsConfig holds the settings of the shared ticket snapshot
+type TicketsConfig struct {
+	Source          string         `yaml:"source"`
+	Windows         []Window       `yaml:"windows"`
+	Statuses        []string       `yaml:"statuses"`
+	RefreshInterval model.Duration `yaml:"refresh_interval"`
+	OnStatusError   string         `yaml:"on_status_error"`
+
+	// Interval between refreshes of the ticket field definitions
+	FieldsRefreshInterval model.Duration `yaml:"fields_refresh_interval"`
+}
+
+// Groupings of numeric custom fields
+const (
+	GroupByTag         = "tag"
+	GroupByCustomField = "custom_field"
+)
+
+// DefaultNumericBuckets are the histogram buckets of numeric fields that
+// don't set their own
+var DefaultNumericBuckets = []float64{1, 5, 10, 50, 100, 500, 1000, 5000, 10000}
+
+// NumericFieldConfig exports the values of a numeric custom field as
+// aggregates instead of labels
+type NumericFieldConfig struct {
+	FieldID        int64     `yaml:"field_id"`
+	GroupBy        string    `yaml:"group_by"`          // empty, tag or custom_field
+	GroupByFieldID int64     `yaml:"group_by_field_id"` // with group_by: custom_field
+	Buckets        []float64 `yaml:"buckets"`
+}
+
+// TicketMetricsConfig holds the settings of the ticket metric histograms
+type TicketMetricsConfig struct {
+	Buckets []float64 `yaml:"buckets"` // in minutes
+}
+
+// BacklogConfig holds the settings of the unsolved ticket backlog
+type BacklogConfig struct {
+	AgeBuckets []model.Duration `yaml:"age_buckets"`
+}
+
+// CollectorConfig holds the settings of a single collector
+type CollectorConfig struct {
+	Enabled         *bool          `yaml:"enabled"`
+	RefreshInterval model.Duration `yaml:"refresh_interval"`
+}
+
+// LabelsConfig holds the filters applied to label values
+type LabelsConfig struct {
+	Tag         FilterConfig `yaml:"tag"`
+	CustomField FilterConfig `yaml:"custom_field"`
+	MaxSeries   int          `yaml:"max_series"` // per metric family, 0 for no limit
+	Dimensions  []string     `yaml:"dimensions"` // labels of zendesk_tickets_count
+
+	// Each group is counted separately in zendesk_tickets_dimension_count
+	DimensionGroups [][]string `yaml:"dimension_groups"`
+}
+
+// FilterConfig decides which values of a label are exported
+type FilterConfig struct {
+	DropNumeric bool    `yaml:"drop_numeric"`
+	Allow       *Regexp `yaml:"allow"`
+	Deny        *Regexp `yaml:"deny"`
+	TopN        int     `yaml:"top_n"` // per status and window, 0 for no limit
+}
+
+// Regexp is a regular expression matching whole values
+type Regexp struct {
+	*regexp.Regexp
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (r *Regexp) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var expr string
+	if err := unmarshal(&expr); err != nil {
+		return err
+	}
+
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid regex %q: %w", expr, err)
+	}
+	r.Regexp = re
+	return nil
+}
+
+// Default returns the configuration used when no file sets a value
+func Default() *Config {
+	return &Config{
+		Tickets: TicketsConfig{
+			Source:          "incremental",
+			Windows:         []Window{{Name: "30d", Duration: 30 * 24 * time.Hour}},
+			Statuses:        []string{"new", "open", "pending", "hold", "solved"},
+			RefreshInterval: model.Duration(5 * time.Minute),
+			OnStatusError:   StatusErrorStale,
+
+			FieldsRefreshInterval: model.Duration(time.Hour),
+		},
+		Collectors: map[string]CollectorConfig{},
+		TicketMetrics: TicketMetricsConfig{
+			// 5m to 30d
+			Buckets: []float64{5, 15, 30, 60, 120, 240, 480, 1440, 2880, 10080, 43200},
+		},
+		Backlog: BacklogConfig{
+			AgeBuckets: []model.Duration{
+				model.Duration(24 * time.Hour),
+				model.Duration(3 * 24 * time.Hour),
+				model.Duration(7 * 24 * time.Hour),
+				model.Duration(30 * 24 * time.Hour),
+			},
+		},
+		Labels: LabelsConfig{
+			CustomField: FilterConfig{DropNumeric: true},
+			MaxSeries:   10000,
+			Dimensions:  slices.Clone(TicketDimensions),
+		},
+	}
+}
+
+// Load reads a YAML configuration file on top of base. Unknown fields are rejected.
+func Load(path string, base *Config) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	// The secrets of the base configuration are only kept when the file sets
+	// none, since only one of them can be set
+	cfg := *base
+	cfg.Zendesk.Credentials = base.Zendesk.Credentials.withoutSecrets()
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	if !cfg.Zendesk.Credentials.hasSecret() {
+		email := cfg.Zendesk.Email
+		cfg.Zendesk.Credentials = base.Zendesk.Credentials
+		cfg.Zendesk.Email = email
+	}
+
+	return &cfg, nil
+}
+
+// Validate checks that the configuration is complete and consistent. The
+// collectors map the name of every available collector to whether it
+// accepts a refresh interval.
+func (c *Config) Validate(collectors map[string]bool) error {
+	// The default account is optional when the exporter only serves probes
+	if c.Zendesk.Subdomain == "" && len(c.Targets) == 0 {
+		return errors.New("zendesk.subdomain must be set (or the ZENDESK_DOMAIN environment variable) unless targets are configured")
+	}
+	if c.Zendesk.Subdomain != "" {
+		if err := c.Zendesk.Credentials.validate(); err != nil {
+			return fmt.Errorf("zendesk: %w (or the matching ZENDESK_* environment variable)", err)
+		}
+	}
+	for name, target := range c.Targets {
+		if err := target.Credentials.validate(); err != nil {
+			return fmt.Errorf("targets.%s: %w", name, err)
+		}
+	}
+	if c.Zendesk.MaxRetries < 0 {
+		return fmt.Errorf("zendesk.max_retries must not be negative, got %d", c.Zendesk.MaxRetries)
+	}
+
+	if c.Tickets.Source != "incremental" && c.Tickets.Source != "search" {
+		return fmt.Errorf("tickets.source must be incremental or search, got %q", c.Tickets.Source)
+	}
+	if len(c.Tickets.Windows) == 0 {
+		return errors.New("tickets.windows must not be empty")
+	}
+	for i, window := range c.Tickets.Windows {
+		for _, other := range c.Tickets.Windows[:i] {
+			if other.Name == window.Name {
+				return fmt.Errorf("tickets.windows: duplicate window %q", window.Name)
+			}
+		}
+	}
+	if c.Tickets.RefreshInterval <= 0 {
+		return errors.New("tickets.refresh_interval must be positive")
+	}
+	if c.Tickets.FieldsRefreshInterval <= 0 {
+		return errors.New("tickets.fields_refresh_interval must be positive")
+	}
+	if len(c.Tickets.Statuses) == 0 {
+		return errors.New("tickets.statuses must not be empty")
+	}
+	for i, status := range c.Tickets.Statuses {
+		if !slices.Contains(Statuses, status) {
+			return fmt.Errorf("tickets.statuses: unknown status %q, must be one of %v", status, Statuses)
+		}
+		if slices.Contains(c.Tickets.Statuses[:i], status) {
+			return fmt.Errorf("tickets.statuses: duplicate status %q", status)
+		}
+	}
+	switch c.Tickets.OnStatusError {
+	case StatusErrorStale, StatusErrorDrop, StatusErrorFail:
+	default:
+		return fmt.Errorf("tickets.on_status_error must be %s, %s or %s, got %q",
+			StatusErrorStale, StatusErrorDrop, StatusErrorFail, c.Tickets.OnStatusError)
+	}
+
+	for name, filter := range map[string]FilterConfig{"tag": c.Labels.Tag, "custom_field": c.Labels.CustomField} {
+		if filter.TopN < 0 {
+			return fmt.Errorf("labels.%s.top_n must not be negative, got %d", name, filter.TopN)
+		}
+	}
+	if c.Labels.MaxSeries < 0 {
+		return fmt.Errorf("labels.max_series must not be negative, got %d", c.Labels.MaxSeries)
+	}
+	if err := validateDimensions("labels.dimensions", c.Labels.Dimensions); err != nil {
+		return err
+	}
+	for i, group := range c.Labels.DimensionGroups {
+		name := fmt.Sprintf("labels.dimension_groups[%d]", i)
+		if len(group) == 0 {
+			return fmt.Errorf("%s must not be empty", name)
+		}
+		if err := validateDimensions(name, group); err != nil {
+			return err
+		}
+		if slices.ContainsFunc(c.Labels.DimensionGroups[:i], func(other []string) bool { return slices.Equal(other, group) }) {
+			return fmt.Errorf("%s: duplicate group %v", name, group)
+		}
+	}
+
+	for name, collector := range c.Collectors {
+		scheduled, ok := collectors[name]
+		if !ok {
+			return fmt.Errorf("collectors: unknown collector %q", name)
+		}
+		if collector.RefreshInterval < 0 {
+			return fmt.Errorf("collectors.%s.refresh_interval must not be negative", name)
+		}
+		// The other collectors are refreshed with the shared ticket snapshot
+		if collector.RefreshInterval != 0 && !scheduled {
+			return fmt.Errorf("collectors.%s.refresh_interval can't be set, the collector is refreshed with tickets.refresh_interval", name)
+		}
+	}
+
+	if len(c.TicketMetrics.Buckets) == 0 || !slices.IsSorted(c.TicketMetrics.Buckets) {
+		return errors.New("ticket_metrics.buckets must not be empty and must be sorted")
+	}
+
+	if len(c.Backlog.AgeBuckets) == 0 {
+		return errors.New("backlog.age_buckets must not be empty")
+	}
+	for i, bucket := range c.Backlog.AgeBuckets {
+		if bucket <= 0 || (i > 0 && bucket <= c.Backlog.AgeBuckets[i-1]) {
+			return errors.New("backlog.age_buckets must be positive and increasing")
+		}
+	}
+
+	for i, field := range c.NumericFields {
+		if field.FieldID == 0 {
+			return fmt.Errorf("numeric_fields[%d].field_id must be set", i)
+		}
+		// The series of a field are only told apart by their group
+		if slices.ContainsFunc(c.NumericFields[:i], func(other NumericFieldConfig) bool { return other.FieldID == field.FieldID }) {
+			return fmt.Errorf("numeric_fields[%d]: duplicate field_id %d, each field can only be listed once", i, field.FieldID)
+		}
+		switch field.GroupBy {
+		case "", GroupByTag:
+		case GroupByCustomField:
+			if field.GroupByFieldID == 0 {
+				return fmt.Errorf("numeric_fields[%d].group_by_field_id must be set with group_by: %s", i, GroupByCustomField)
+			}
+		default:
+			return fmt.Errorf("numeric_fields[%d].group_by must be %s or %s, got %q", i, GroupByTag, GroupByCustomField, field.GroupBy)
+		}
+		if !slices.IsSorted(field.Buckets) {
+			return fmt.Errorf("numeric_fields[%d].buckets must be sorted", i)
+		}
+	}
+
+	return nil
+}
+
+// validateDimensions checks that a list of dimensions only holds distinct
+// ticket dimensions
+func validateDimensions(name string, dimensions []string) error {
+	for i, dimension := range dimensions {
+		if !slices.Contains(TicketDimensions, dimension) {
+			return fmt.Errorf("%s: unknown label %q, must be one of %v", name, dimension, TicketDimensions)
+		}
+		if slices.Contains(dimensions[:i], dimension) {
+			return fmt.Errorf("%s: duplicate label %q", name, dimension)
+		}
+	}
+	return nil
+}
+
+// Target returns the Zendesk settings of a probe target. The target shares
+// the client settings of the default account.
+func (c *Config) Target(name string) (ZendeskConfig, bool) {
+	target, ok := c.Targets[name]
+	if !ok {
+		return ZendeskConfig{}, false
+	}
+
+	return ZendeskConfig{
+		Subdomain:   name,
+		Credentials: target.Credentials,
+		MaxRetries:  c.Zendesk.MaxRetries,
+	}, true
+}
+
+// MonthToDate is the name of the window starting at the beginning of the current month
+const MonthToDate = "month_to_date"
+
+// Window is a lookback period for ticket metrics. It is written either as a
+// Prometheus duration such as 7d or as month_to_date.
+type Window struct {
+	Name        string
+	Duration    time.Duration
+	MonthToDate bool
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (w *Window) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err != nil {
+		return err
+	}
+
+	if name == MonthToDate {
+		*w = Window{Name: name, MonthToDate: true}
+		return nil
+	}
+
+	d, err := model.ParseDuration(name)
+	if err != nil {
+		return fmt.Errorf("invalid window %q, must be a duration or %s: %w", name, MonthToDate, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("invalid window %q, must be positive", name)
+	}
+	*w = Window{Name: name, Duration: time.Duration(d)}
+	return nil
+}
+
+// Start returns the beginning of the window ending at now
+func (w Window) Start(now time.Time) time.Time {
+	if w.MonthToDate {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	}
+	return now.Add(-w.Duration)
+}
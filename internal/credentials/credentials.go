@@ -0,0 +1,110 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsxbet/zendesk_exporter/internal/config"
+
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// New returns the credential described by the configuration. Secret files
+// are read once here, so a missing or empty file fails at startup.
+func New(cfg config.Credentials) (zendesk.Credential, error) {
+	switch {
+	case cfg.APIToken != "":
+		return zendesk.NewAPITokenCredential(cfg.Email, cfg.APIToken), nil
+	case cfg.OAuthToken != "":
+		return zendesk.NewBearerTokenCredential(cfg.OAuthToken), nil
+	case cfg.APITokenFile != "":
+		return newFileCredential(cfg.APITokenFile, cfg.Email+"/token", false)
+	case cfg.OAuthTokenFile != "":
+		return newFileCredential(cfg.OAuthTokenFile, "", true)
+	}
+	return nil, errors.New("no credentials configured")
+}
+
+// FileCredential is a zendesk.Credential whose secret is read from a file,
+// such as a Kubernetes secret mount. The file is reloaded whenever its
+// modification time changes, so rotated secrets are picked up without a
+// restart.
+type FileCredential struct {
+	path   string
+	email  string
+	bearer bool
+
+	mu      sync.Mutex
+	secret  string
+	modTime time.Time
+}
+
+func newFileCredential(path, email string, bearer bool) (*FileCredential, error) {
+	c := &FileCredential{
+		path:   path,
+		email:  email,
+		bearer: bearer,
+	}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Email implements zendesk.Credential
+func (c *FileCredential) Email() string {
+	return c.email
+}
+
+// Secret implements zendesk.Credential. When the file can't be reloaded, the
+// last secret read is kept.
+func (c *FileCredential) Secret() string {
+	if err := c.reload(); err != nil {
+		log.Printf("Error reloading credentials: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.secret
+}
+
+// Bearer implements zendesk.Credential
+func (c *FileCredential) Bearer() bool {
+	return c.bearer
+}
+
+// reload reads the secret file again if it changed since the last read
+func (c *FileCredential) reload() error {
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return fmt.Errorf("error reading secret file: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if info.ModTime().Equal(c.modTime) {
+		return nil
+	}
+
+	content, err := os.ReadFile(c.path)
+	if err != nil {
+		return fmt.Errorf("error reading secret file: %w", err)
+	}
+	secret := strings.TrimSpace(string(content))
+	if secret == "" {
+		return fmt.Errorf("secret file %s is empty", c.path)
+	}
+
+	if !c.modTime.IsZero() {
+		log.Printf("Reloaded credentials from %s", c.path)
+	}
+	c.secret = secret
+	c.modTime = info.ModTime()
+	return nil
+}
@@ -0,0 +1,177 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/nsxbet/zendesk_exporter/internal/collector"
+	"github.com/nsxbet/zendesk_exporter/internal/config"
+	"github.com/nsxbet/zendesk_exporter/internal/credentials"
+	"github.com/nsxbet/zendesk_exporter/internal/transport"
+
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// HandlerOpts keeps serving the other metrics when a collector reports an error
+var HandlerOpts = promhttp.HandlerOpts{
+	ErrorLog:      log.Default(),
+	ErrorHandling: promhttp.ContinueOnError,
+}
+
+// Exporter is the set of enabled collectors exporting the metrics of a
+// single Zendesk account
+type Exporter struct {
+	scheduler *collector.Scheduler
+}
+
+// New creates the collectors of a Zendesk account and registers them, along
+// with the exporter's own metrics, with reg. The credentials of the account
+// are checked first, so misconfigured accounts fail fast.
+func New(ctx context.Context, cfg *config.Config, account config.ZendeskConfig, reg prometheus.Registerer) (*Exporter, error) {
+	rateLimitTransport := transport.NewRateLimitTransport(newHTTPTransport(), account.MaxRetries)
+	if err := reg.Register(rateLimitTransport); err != nil {
+		return nil, err
+	}
+
+	client, err := newZendeskClient(account, rateLimitTransport)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyCredentials(ctx, client, account.Subdomain); err != nil {
+		return nil, err
+	}
+
+	statusTracker := collector.NewStatusTracker(cfg.Tickets.OnStatusError)
+	if err := reg.Register(statusTracker); err != nil {
+		return nil, err
+	}
+
+	limiter := collector.NewSeriesLimiter(cfg.Labels.MaxSeries)
+	if err := reg.Register(limiter); err != nil {
+		return nil, err
+	}
+
+	// Fetch tickets once per refresh and share them between collectors
+	ticketSource := newTicketSource(cfg.Tickets.Source, client, cfg.Tickets.Statuses, statusTracker)
+	if c, ok := ticketSource.(prometheus.Collector); ok {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	ticketStore := collector.NewTicketStore(ticketSource, cfg.Tickets.Windows)
+
+	// Create and register enabled collectors
+	scheduler := collector.NewScheduler()
+	if err := reg.Register(scheduler); err != nil {
+		return nil, err
+	}
+
+	// Load the ticket field definitions and the groups before the first
+	// ticket snapshot, so custom fields and groups are labeled from the start
+	ticketFields := collector.NewTicketFieldCache(client)
+	scheduler.AddRefreshed(ctx, "ticket_fields", ticketFields, time.Duration(cfg.Tickets.FieldsRefreshInterval))
+
+	groups := collector.NewGroupCache(client)
+	scheduler.AddRefreshed(ctx, "groups", groups, time.Duration(cfg.Tickets.FieldsRefreshInterval))
+
+	collectors, err := collector.NewCollectors(&collector.Config{
+		Client:        client,
+		Tickets:       ticketStore,
+		Fields:        ticketFields,
+		Groups:        groups,
+		Scheduler:     scheduler,
+		StatusTracker: statusTracker,
+		Limiter:       limiter,
+		Statuses:      cfg.Tickets.Statuses,
+		Windows:       cfg.Tickets.Windows,
+		Collectors:    cfg.Collectors,
+		Labels:        cfg.Labels,
+		NumericFields: cfg.NumericFields,
+		TicketMetrics: cfg.TicketMetrics,
+		Backlog:       cfg.Backlog,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for name, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return nil, fmt.Errorf("error registering %s collector: %w", name, err)
+		}
+	}
+
+	if ticketStore.HasConsumers() {
+		scheduler.Add(collector.SnapshotName, ticketStore, time.Duration(cfg.Tickets.RefreshInterval))
+	}
+
+	return &Exporter{scheduler: scheduler}, nil
+}
+
+// Run refreshes the collectors in the background until the context is
+// cancelled, so scrapes only serve cached metrics
+func (e *Exporter) Run(ctx context.Context) {
+	e.scheduler.Run(ctx)
+}
+
+func newZendeskClient(account config.ZendeskConfig, rt http.RoundTripper) (*zendesk.Client, error) {
+	// No overall client timeout, as it would include the time spent waiting
+	// between retries
+	client, err := zendesk.NewClient(&http.Client{Transport: rt})
+	if err != nil {
+		return nil, fmt.Errorf("error creating client: %w", err)
+	}
+
+	if err := client.SetSubdomain(account.Subdomain); err != nil {
+		return nil, fmt.Errorf("invalid Zendesk subdomain: %w", err)
+	}
+
+	credential, err := credentials.New(account.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	client.SetCredential(credential)
+
+	return client, nil
+}
+
+// verifyCredentials fetches the user the client is authenticated as
+func verifyCredentials(ctx context.Context, client *zendesk.Client, subdomain string) error {
+	body, err := client.Get(ctx, "/users/me.json")
+	if err != nil {
+		return fmt.Errorf("error verifying credentials: %w", err)
+	}
+
+	var me struct {
+		User zendesk.User `json:"user"`
+	}
+	if err := json.Unmarshal(body, &me); err != nil {
+		return fmt.Errorf("error decoding current user: %w", err)
+	}
+
+	// Zendesk answers with an anonymous user when the credentials aren't accepted
+	if me.User.ID == 0 {
+		return fmt.Errorf("credentials for %s were not accepted", subdomain)
+	}
+
+	log.Printf("Authenticated to %s as %s (%s)", subdomain, me.User.Email, me.User.Role)
+	return nil
+}
+
+// newHTTPTransport returns the transport used for each individual Zendesk API request
+func newHTTPTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ResponseHeaderTimeout = time.Second * 30
+	return t
+}
+
+func newTicketSource(source string, client *zendesk.Client, statuses []string, tracker *collector.StatusTracker) collector.TicketSource {
+	if source == "search" {
+		return collector.NewSearchSource(client, statuses, tracker)
+	}
+	return collector.NewIncrementalSource(client, statuses)
+}
@@ -0,0 +1,93 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/nsxbet/zendesk_exporter/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ProbeHandler serves the metrics of the configured targets on
+// /probe?target=<subdomain>. The collectors of a target are created on its
+// first probe and then refreshed in the background like the default account.
+type ProbeHandler struct {
+	cfg *config.Config
+
+	mu      sync.Mutex
+	targets map[string]*probeTarget
+}
+
+// probeTarget holds the metrics handler of a target. Its own lock lets the
+// collectors of a target be created without blocking the probes of the others.
+type probeTarget struct {
+	mu      sync.Mutex
+	handler http.Handler
+}
+
+// NewProbeHandler creates a new ProbeHandler
+func NewProbeHandler(cfg *config.Config) *ProbeHandler {
+	return &ProbeHandler{
+		cfg:     cfg,
+		targets: make(map[string]*probeTarget),
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (p *ProbeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := p.cfg.Targets[target]; !ok {
+		http.Error(w, fmt.Sprintf("unknown target %q", target), http.StatusBadRequest)
+		return
+	}
+
+	handler, err := p.handler(r.Context(), target)
+	if err != nil {
+		log.Printf("Error probing target %s: %v", target, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// handler returns the metrics handler of a target, creating its collectors
+// on the first call
+func (p *ProbeHandler) handler(ctx context.Context, target string) (http.Handler, error) {
+	p.mu.Lock()
+	t, ok := p.targets[target]
+	if !ok {
+		t = &probeTarget{}
+		p.targets[target] = t
+	}
+	p.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.handler != nil {
+		return t.handler, nil
+	}
+
+	account, _ := p.cfg.Target(target)
+
+	registry := prometheus.NewRegistry()
+	e, err := New(ctx, p.cfg, account, registry)
+	if err != nil {
+		return nil, fmt.Errorf("error creating collectors: %w", err)
+	}
+	go e.Run(context.Background())
+	log.Printf("Started collectors for target %s", target)
+
+	t.handler = promhttp.HandlerFor(registry, HandlerOpts)
+	return t.handler, nil
+}
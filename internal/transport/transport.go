@@ -0,0 +1,175 @@
+package transport
+
+import (
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	baseBackoff = time.Second
+	maxBackoff  = time.Minute
+)
+
+// idPattern matches numeric path segments, which are replaced to keep the
+// endpoint label bounded
+var idPattern = regexp.MustCompile(`/[0-9]+(/|\.|$)`)
+
+// RateLimitTransport is an http.RoundTripper that retries rate limited and
+// failed Zendesk API requests and tracks the remaining rate limit budget
+type RateLimitTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+
+	requests  *prometheus.CounterVec
+	limit     prometheus.Gauge
+	remaining prometheus.Gauge
+	retries   *prometheus.CounterVec
+}
+
+// NewRateLimitTransport creates a new RateLimitTransport wrapping next
+func NewRateLimitTransport(next http.RoundTripper, maxRetries int) *RateLimitTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &RateLimitTransport{
+		next:       next,
+		maxRetries: maxRetries,
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "zendesk_api_requests_total",
+				Help: "Number of Zendesk API requests by endpoint and status code, including retries",
+			},
+			[]string{"endpoint", "code"},
+		),
+		limit: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "zendesk_api_rate_limit",
+			Help: "Number of Zendesk API requests allowed per minute, from the X-Rate-Limit header",
+		}),
+		remaining: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "zendesk_api_rate_limit_remaining",
+			Help: "Number of Zendesk API requests remaining in the current minute, from the X-Rate-Limit-Remaining header",
+		}),
+		retries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "zendesk_api_retries_total",
+				Help: "Number of retried Zendesk API requests by reason",
+			},
+			[]string{"reason"},
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (t *RateLimitTransport) Describe(ch chan<- *prometheus.Desc) {
+	t.requests.Describe(ch)
+	t.limit.Describe(ch)
+	t.remaining.Describe(ch)
+	t.retries.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (t *RateLimitTransport) Collect(ch chan<- prometheus.Metric) {
+	t.requests.Collect(ch)
+	t.limit.Collect(ch)
+	t.remaining.Collect(ch)
+	t.retries.Collect(ch)
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := idPattern.ReplaceAllString(req.URL.Path, "/:id$1")
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if err != nil {
+			t.requests.WithLabelValues(endpoint, "error").Inc()
+			return nil, err
+		}
+		t.requests.WithLabelValues(endpoint, strconv.Itoa(resp.StatusCode)).Inc()
+		t.observe(resp.Header)
+
+		var reason string
+		var delay time.Duration
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			reason = "rate_limited"
+			delay = retryAfter(resp.Header, attempt)
+		case resp.StatusCode >= http.StatusInternalServerError:
+			reason = "server_error"
+			delay = backoff(attempt)
+		default:
+			return resp, nil
+		}
+
+		// Give up and let the caller handle the error response
+		if attempt >= t.maxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		t.retries.WithLabelValues(reason).Inc()
+		log.Printf("Zendesk API returned %d for %s, retrying in %s", resp.StatusCode, req.URL.Path, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// observe records the rate limit headers of a response
+func (t *RateLimitTransport) observe(header http.Header) {
+	if v, err := strconv.ParseFloat(header.Get("X-Rate-Limit"), 64); err == nil {
+		t.limit.Set(v)
+	}
+	if v, err := strconv.ParseFloat(header.Get("X-Rate-Limit-Remaining"), 64); err == nil {
+		t.remaining.Set(v)
+	}
+}
+
+// retryAfter returns the delay requested by the Retry-After header, falling
+// back to exponential backoff when it is missing or invalid
+func retryAfter(header http.Header, attempt int) time.Duration {
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+		return 0
+	}
+	return backoff(attempt)
+}
+
+// backoff returns an exponential backoff delay with full jitter
+func backoff(attempt int) time.Duration {
+	delay := maxBackoff
+	if attempt < 16 {
+		delay = min(baseBackoff<<attempt, maxBackoff)
+	}
+	return time.Duration(rand.Int64N(int64(delay)))
+}